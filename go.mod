@@ -1,6 +1,6 @@
 module github.com/tekwizely/run
 
-go 1.13
+go 1.18
 
 // To update:
 //
@@ -9,9 +9,16 @@ go 1.13
 // $ go get github.com/tekwizely/go-parsing/parser@master
 //
 require (
+	github.com/BurntSushi/toml v1.2.1
 	github.com/goreleaser/fileglob v1.3.0
 	github.com/subosito/gotenv v1.6.0
 	github.com/tekwizely/go-parsing/lexer v0.0.0-20210910181107-ed69a13f4d15
 	github.com/tekwizely/go-parsing/lexer/token v0.0.0-20210910181107-ed69a13f4d15
 	github.com/tekwizely/go-parsing/parser v0.0.0-20210910181107-ed69a13f4d15
+	gopkg.in/yaml.v3 v3.0.1
+)
+
+require (
+	github.com/gobwas/glob v0.2.3 // indirect
+	golang.org/x/text v0.12.0 // indirect
 )
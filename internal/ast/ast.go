@@ -3,10 +3,17 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/goreleaser/fileglob"
 	"github.com/subosito/gotenv"
@@ -33,6 +40,12 @@ func ProcessAST(ast *Ast) *runfile.Runfile {
 	rf.Scope.PutAttr(".RUNFILE.DIR", config.RunfileAbsDir)
 	rf.Scope.PutAttr(".SELF", config.CurrentRunfileAbs)
 	rf.Scope.PutAttr(".SELF.DIR", config.CurrentRunfileAbsDir)
+	rf.Scope.PutAttr(".PROFILE", config.Profile)
+	// Seed command-line variable overrides
+	//
+	for name, value := range config.VarOverrides {
+		rf.Scope.PutVar(name, value)
+	}
 	for _, n := range ast.nodes {
 		n.Apply(rf)
 	}
@@ -48,9 +61,11 @@ func ProcessAstRunfile(ast *Ast, rf *runfile.Runfile) {
 	//
 	selfRunfileBak, _ := rf.Scope.GetAttr(".SELF")
 	selfRunfileDirBak, _ := rf.Scope.GetAttr(".SELF.DIR")
+	groupBak, _ := rf.Scope.GetAttr(".GROUP") // .GROUP set within included runfile should not leak out
 	defer func() {
 		rf.Scope.PutAttr(".SELF", selfRunfileBak)
 		rf.Scope.PutAttr(".SELF.DIR", selfRunfileDirBak)
+		rf.Scope.PutAttr(".GROUP", groupBak)
 	}()
 	rf.Scope.PutAttr(".SELF", config.CurrentRunfileAbs)
 	rf.Scope.PutAttr(".SELF.DIR", config.CurrentRunfileAbsDir)
@@ -196,27 +211,73 @@ func (a *ScopeAssert) Apply(s *runfile.Scope) {
 	assert := &runfile.Assert{}
 	assert.Runfile = a.Runfile
 	assert.Line = a.Line
-	assert.Test = a.Test.Apply(s)
-	assert.Message = strings.TrimSpace(a.Message.Apply(s))
+	assert.Test = lazyValue(a.Test, s)
+	assert.Message = lazyValue(a.Message, s)
 	s.AddAssert(assert)
 }
 
-// ScopeInclude includes other runfiles.
+// IfBlock wraps a conditional block, i.e. 'IF' / 'ELSE' / 'END'.
+// The condition is evaluated when the Runfile is loaded, and only the matching branch is applied.
 //
-type ScopeInclude struct {
-	FilePattern       ScopeValueNode
-	MissingSingleOk   bool
-	MissingMatchersOk bool
+type IfBlock struct {
+	Runfile string
+	Line    int
+	Cond    IfCondition
+	Then    *Ast
+	Else    *Ast
 }
 
-// Apply applies the node to the scope.
+// Apply applies the node to the runfile.
 //
-func (a *ScopeInclude) Apply(r *runfile.Runfile) {
-	filePattern := a.FilePattern.Apply(r.Scope)
-	var (
-		files []string
-		err   error
-	)
+func (a *IfBlock) Apply(r *runfile.Runfile) {
+	branch := a.Else
+	if a.Cond.Eval(r.Scope) {
+		branch = a.Then
+	}
+	for _, n := range branch.nodes {
+		n.Apply(r)
+	}
+}
+
+// IfCondition is the condition of an IF block.
+//
+type IfCondition interface {
+	Eval(s *runfile.Scope) bool
+}
+
+// IfTest is a test condition, i.e. '[ -n "${CI}" ]'.
+// Uses the same syntax as ASSERT, and passes if the test exits with code 0.
+//
+type IfTest struct {
+	Test ScopeValueNode
+}
+
+// Eval evaluates the condition.
+// The test is invoked using the global .SHELL, with the scope's exported variables.
+//
+func (a *IfTest) Eval(s *runfile.Scope) bool {
+	return exec.ExecuteSubCommand(scopeShell(s), a.Test.Apply(s), exportEnv(s), ioutil.Discard, os.Stderr) == 0
+}
+
+// IfCompare is a comparison condition, i.e. '${VAR} == value' | '${VAR} != value'.
+//
+type IfCompare struct {
+	Left   ScopeValueNode
+	Right  ScopeValueNode
+	Negate bool // '!='
+}
+
+// Eval evaluates the condition.
+//
+func (a *IfCompare) Eval(s *runfile.Scope) bool {
+	return (a.Left.Apply(s) == a.Right.Apply(s)) != a.Negate
+}
+
+// globIncludeFiles resolves an include file pattern into a list of absolute file names.
+// Specific (not-glob) filenames are returned as-is, as the caller is expected to check they exist.
+// kind is used for messaging, i.e. "include.env".
+//
+func globIncludeFiles(kind string, filePattern string, missingMatchersOk bool) []string {
 	// We want the absolute file paths for include tracking
 	// If pattern is not absolute, assume its relative to config.RunfileAbsDir
 	//
@@ -227,37 +288,62 @@ func (a *ScopeInclude) Apply(r *runfile.Runfile) {
 	// By checking this ourselves, we hope to gain more control over error reporting,
 	// as fileglob currently (as of v1.3.0) conceals the fs.ErrorNotExist condition.
 	//
-	if fileglob.ContainsMatchers(filePattern) {
-		if files, err = fileglob.Glob(filePattern, fileglob.MaybeRootFS); err != nil {
-			panic(fmt.Errorf("processing include pattern '%s': %s", filePattern, err))
-		} else if len(files) == 0 {
-			if a.MissingMatchersOk {
-				// OK for fileglob to result in 0 files, but notify user
-				//
-				if config.ShowNotices {
-					log.Printf("NOTICE: include pattern resulted in no matches: %s", filePattern)
-				}
-			} else {
-				panic(fmt.Errorf("include pattern resulted in no matches: %s", filePattern))
+	if !fileglob.ContainsMatchers(filePattern) {
+		return []string{filePattern}
+	}
+	files, err := fileglob.Glob(filePattern, fileglob.MaybeRootFS)
+	if err != nil {
+		panic(fmt.Errorf("processing %s pattern '%s': %s", kind, filePattern, err))
+	} else if len(files) == 0 {
+		if missingMatchersOk {
+			// OK for fileglob to result in 0 files, but notify user
+			//
+			if config.ShowNotices {
+				log.Printf("NOTICE: %s pattern resulted in no matches: %s", kind, filePattern)
 			}
+		} else {
+			panic(fmt.Errorf("%s pattern resulted in no matches: %s", kind, filePattern))
 		}
-	} else {
-		// Specific (not-glob) filename expected to exist - Checked in loop below
-		//
-		files = []string{filePattern}
 	}
+	return files
+}
+
+// ScopeInclude includes other runfiles.
+//
+type ScopeInclude struct {
+	FilePattern       ScopeValueNode
+	Namespace         string // Optional; Commands from included runfiles are registered as 'namespace:name'
+	MissingSingleOk   bool
+	MissingMatchersOk bool
+}
+
+// Apply applies the node to the scope.
+//
+func (a *ScopeInclude) Apply(r *runfile.Runfile) {
+	files := globIncludeFiles("include", a.FilePattern.Apply(r.Scope), a.MissingMatchersOk)
 	// Save log prefix and current runfile values, restore before leaving
 	//
 	logPrefixBak := log.Prefix()
 	currentRunfileBak := config.CurrentRunfile
 	currentRunfileAbsBak := config.CurrentRunfileAbs
 	currentRunfileAbsDirBak := config.CurrentRunfileAbsDir
+	currentNamespaceBak := config.CurrentNamespace
 	defer func() {
 		log.SetPrefix(logPrefixBak)
 		config.CurrentRunfile = currentRunfileBak
 		config.CurrentRunfileAbs = currentRunfileAbsBak
 		config.CurrentRunfileAbsDir = currentRunfileAbsDirBak
+		config.CurrentNamespace = currentNamespaceBak
 	}()
+	// Nested namespaces are joined with ':'
+	//
+	if len(a.Namespace) > 0 {
+		if len(config.CurrentNamespace) > 0 {
+			config.CurrentNamespace = config.CurrentNamespace + ":" + a.Namespace
+		} else {
+			config.CurrentNamespace = a.Namespace
+		}
+	}
 	// NOTE: filenames assumed to be absolute
 	// TODO Sort list (path aware) ?
 	//
@@ -327,6 +413,9 @@ func (a *ScopeInclude) Apply(r *runfile.Runfile) {
 //
 type ScopeIncludeEnv struct {
 	FilePattern       ScopeValueNode
+	Profiles          []string // Only include if active profile is in list
+	Export            bool     // Export all variables from the file(s)
+	Expand            bool     // Expand variable references against the runfile scope
 	MissingSingleOk   bool
 	MissingMatchersOk bool
 }
@@ -334,88 +423,233 @@ type ScopeIncludeEnv struct {
 // Apply applies the node to the scope.
 //
 func (a *ScopeIncludeEnv) Apply(r *runfile.Runfile) {
-	filePattern := a.FilePattern.Apply(r.Scope)
-	// We want the absolute file paths for include tracking
-	// If pattern is not absolute, assume its relative to config.RunfileAbsDir
+	// Profile-specific include?
 	//
-	if !filepath.IsAbs(filePattern) {
-		filePattern = filepath.Join(config.RunfileAbsDir, filePattern)
+	if len(a.Profiles) > 0 && !a.profileActive() {
+		return
 	}
-	// Skip fileglob if pattern does not look like a glob.
-	// By checking this ourselves, we hope to gain more control over error reporting,
-	// as fileglob currently (as of v1.3.0) conceals the fs.ErrorNotExist condition.
+	files := globIncludeFiles("include.env", a.FilePattern.Apply(r.Scope), a.MissingMatchersOk)
+	// NOTE: filenames assumed to be absolute
+	// TODO Sort list (path aware) ?
 	//
-	var files []string
-	if fileglob.ContainsMatchers(filePattern) {
-		var err error
-		if files, err = fileglob.Glob(filePattern, fileglob.MaybeRootFS); err != nil {
-			panic(fmt.Errorf("processing include.env pattern '%s': %s", filePattern, err))
-		} else if len(files) == 0 {
-			if a.MissingMatchersOk {
-				// OK for fileglob to result in 0 files, but notify user
-				//
-				if config.ShowNotices {
-					log.Printf("NOTICE: include.env pattern resulted in no matches: %s", filePattern)
-				}
-			} else {
-				panic(fmt.Errorf("include.env pattern resulted in no matches: %s", filePattern))
-			}
+	for _, filename := range files {
+		a.includeFile(r, filename, a.MissingSingleOk)
+		// Active profile? Also include '<file>.<profile>', if present
+		// Values from the profile file take precedence
+		//
+		if len(config.Profile) > 0 && len(a.Profiles) == 0 {
+			a.includeFile(r, filename+"."+config.Profile, true)
+		}
+	}
+}
+
+// profileActive returns true if the active profile is one of the include's profiles.
+//
+func (a *ScopeIncludeEnv) profileActive() bool {
+	for _, profile := range a.Profiles {
+		if strings.EqualFold(profile, config.Profile) {
+			return true
+		}
+	}
+	return false
+}
+
+// includeFile includes the variables from a .env file.
+// filename is assumed to be absolute.
+//
+func (a *ScopeIncludeEnv) includeFile(r *runfile.Runfile, filename string, missingOk bool) {
+	// Have we included this file already?
+	//
+	if _, exists := config.IncludeEnvCycleMap[filename]; exists {
+		// Treat as a notice since we safely avoided the (possibly) infinite loop
+		//
+		if config.ShowNotices {
+			log.Printf("NOTICE: env file already included: '%s' - Skipping", filename)
+		}
+		return
+	}
+	fileBytes, exists := readIncludeFile("include.env", filename, missingOk)
+	if !exists {
+		return
+	}
+	// Mark file included
+	//
+	config.IncludeEnvCycleMap[filename] = struct{}{}
+	// Parse the file
+	// If expanding, leave references un-expanded so we can expand them against the scope
+	//
+	var dotEnv map[string]string
+	var err error
+	if a.Expand {
+		if dotEnv, err = util.ParseDotEnvRefs(fileBytes); err == nil {
+			dotEnv = expandDotEnv(dotEnv, r.Scope)
 		}
 	} else {
-		// Specific (not-glob) filename expected to exist - Checked in loop below
+		dotEnv, err = gotenv.StrictParse(bytes.NewReader(fileBytes))
+	}
+	if err != nil {
+		panic(fmt.Errorf("include.env file '%s': %s", filename, err.Error()))
+	}
+	// Sort for consistent export order
+	//
+	names := make([]string, 0, len(dotEnv))
+	for k := range dotEnv {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	for _, k := range names {
+		r.Scope.PutVar(k, dotEnv[k])
+		// No values exported by default
 		//
-		files = []string{filePattern}
+		if a.Export {
+			r.Scope.ExportVar(k)
+		}
 	}
+}
+
+// ScopeIncludeVars includes variables from structured (json, yaml, toml) files.
+//
+type ScopeIncludeVars struct {
+	FilePattern       ScopeValueNode
+	KeyPath           string // Optional sub-tree to include, i.e. 'db.primary'
+	MissingSingleOk   bool
+	MissingMatchersOk bool
+}
+
+// Apply applies the node to the scope.
+//
+func (a *ScopeIncludeVars) Apply(r *runfile.Runfile) {
+	files := globIncludeFiles("include.vars", a.FilePattern.Apply(r.Scope), a.MissingMatchersOk)
 	// NOTE: filenames assumed to be absolute
-	// TODO Sort list (path aware) ?
 	//
 	for _, filename := range files {
-		// Have we included this file already?
+		fileBytes, exists := readIncludeFile("include.vars", filename, a.MissingSingleOk)
+		if !exists {
+			continue
+		}
+		data, err := util.ParseVarsFile(filename, fileBytes)
+		if err == nil && len(a.KeyPath) > 0 {
+			data, err = util.SelectVarsTree(data, a.KeyPath)
+		}
+		if err != nil {
+			panic(fmt.Errorf("include.vars file '%s': %s", filename, err.Error()))
+		}
+		vars, err := util.FlattenVars(data)
+		if err != nil {
+			panic(fmt.Errorf("include.vars file '%s': %s", filename, err.Error()))
+		}
+		// Sort for consistent assignment order
+		// No values exported by default
 		//
-		if _, exists := config.IncludeEnvCycleMap[filename]; exists {
-			// Treat as a notice since we safely avoided the (possibly) infinite loop
-			//
-			if config.ShowNotices {
-				log.Printf("NOTICE: env file already included: '%s' - Skipping", filename)
-			}
-		} else {
-			fileBytes, exists, err := util.ReadFileIfExists(filename)
-			if exists {
-				// Mark file included
-				//
-				config.IncludeEnvCycleMap[filename] = struct{}{}
-				// Parse the file
-				//
-				dotEnv, err := gotenv.StrictParse(bytes.NewReader(fileBytes))
-				if err != nil {
-					panic(fmt.Errorf("include.env file '%s': %s", filename, err.Error()))
-				}
-				// No values exported by default
-				//
-				for k, v := range dotEnv {
-					r.Scope.PutVar(k, v)
-				}
-			} else {
-				if err == nil {
-					if a.MissingSingleOk {
-						// OK if file missing, but notify user
-						//
-						if config.ShowNotices {
-							log.Printf("NOTICE: include.env file not found: '%s'", filename)
-						}
-					} else {
-						panic(fmt.Errorf("include.env file not found: '%s'", filename))
-					}
-				} else {
-					// If path error, just show the wrapped error
-					//
-					if pathErr, ok := err.(*os.PathError); ok {
-						err = pathErr.Unwrap()
-					}
-					panic(fmt.Errorf("include.env file '%s': %s", filename, err.Error()))
-				}
-			}
+		names := make([]string, 0, len(vars))
+		for k := range vars {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			r.Scope.PutVar(k, vars[k])
+		}
+	}
+}
+
+// readIncludeFile reads an included file.
+// Returns false if the file does not exist, and missing files are OK.
+// kind is used for messaging, i.e. "include.env".
+//
+func readIncludeFile(kind string, filename string, missingOk bool) ([]byte, bool) {
+	fileBytes, exists, err := util.ReadFileIfExists(filename)
+	if exists {
+		return fileBytes, true
+	}
+	if err == nil {
+		if !missingOk {
+			panic(fmt.Errorf("%s file not found: '%s'", kind, filename))
+		}
+		// OK if file missing, but notify user
+		//
+		if config.ShowNotices {
+			log.Printf("NOTICE: %s file not found: '%s'", kind, filename)
+		}
+		return nil, false
+	}
+	// If path error, just show the wrapped error
+	//
+	if pathErr, ok := err.(*os.PathError); ok {
+		err = pathErr.Unwrap()
+	}
+	panic(fmt.Errorf("%s file '%s': %s", kind, filename, err.Error()))
+}
+
+// expandDotEnv expands variable references within .env values.
+// References resolve to (in order): other variables in the file, runfile variables, environment variables.
+// A variable referencing itself resolves to the runfile / environment value, allowing FOO=${FOO}:more.
+// Use '$$' for a literal '$'.
+//
+func expandDotEnv(dotEnv map[string]string, s *runfile.Scope) map[string]string {
+	expanded := make(map[string]string, len(dotEnv))
+	resolving := make(map[string]bool)
+	var expand func(name string) string
+	lookup := func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		if _, ok := dotEnv[name]; ok && !resolving[name] {
+			return expand(name)
+		}
+		if val, ok := s.GetVar(name); ok {
+			return val
 		}
+		val, _ := s.GetEnv(name)
+		return val
+	}
+	expand = func(name string) string {
+		if val, ok := expanded[name]; ok {
+			return val
+		}
+		resolving[name] = true
+		val := os.Expand(dotEnv[name], lookup)
+		resolving[name] = false
+		expanded[name] = val
+		return val
+	}
+	for name := range dotEnv {
+		expand(name)
+	}
+	return expanded
+}
+
+// RunfileHook wraps a BEFORE.ALL / AFTER.ALL hook.
+//
+type RunfileHook struct {
+	After    bool
+	Command  string
+	Patterns []ScopeValueNode
+	Runfile  string
+	Line     int
+}
+
+// Apply applies the node to the runfile.
+//
+func (a *RunfileHook) Apply(r *runfile.Runfile) {
+	hook := &runfile.Hook{
+		Command:   a.Command,
+		Namespace: config.CurrentNamespace,
+		Runfile:   a.Runfile,
+		Line:      a.Line,
+	}
+	for _, pattern := range a.Patterns {
+		// Command names are normalized, so patterns are too
+		//
+		value := strings.ToLower(pattern.Apply(r.Scope))
+		if _, err := path.Match(value, ""); err != nil {
+			panic(fmt.Errorf("%s:%d: invalid command pattern '%s': %s", a.Runfile, a.Line, value, err))
+		}
+		hook.Patterns = append(hook.Patterns, value)
+	}
+	if a.After {
+		r.AfterAll = append(r.AfterAll, hook)
+	} else {
+		r.BeforeAll = append(r.BeforeAll, hook)
 	}
 }
 
@@ -494,17 +728,21 @@ func (a *ScopeDParenString) Apply(s *runfile.Scope) string {
 // Cmd wraps a parsed command.
 //
 type Cmd struct {
-	Flags   config.CmdFlags
-	Name    string
-	Config  *CmdConfig
-	Script  []string
-	Runfile string
-	Line    int
+	Flags     config.CmdFlags
+	Name      string
+	Config    *CmdConfig
+	Script    []string
+	Runfile   string
+	Line      int
+	Group     string // Value of .GROUP when command was defined
+	Namespace string // Namespace of the including runfile(s)
 }
 
 // Apply applies the node to the runfile.
 //
 func (a *Cmd) Apply(r *runfile.Runfile) {
+	a.Group, _ = r.Scope.GetAttr(".GROUP")
+	a.Namespace = config.CurrentNamespace
 	r.Cmds = append(r.Cmds, a)
 }
 
@@ -515,19 +753,92 @@ func (a *Cmd) GetCmd(r *runfile.Runfile) *runfile.RunCmd {
 	return a.GetCmdEnv(r, map[string]string{})
 }
 
+// DescribeCmd generates a Runfile command from the node, for listing.
+// Assignments to readonly variables within the doc block are skipped, rather than reported,
+// so they only fail the command being run.
+// Fulfills runfile.CmdProvider#DescribeCmd
+//
+func (a *Cmd) DescribeCmd(r *runfile.Runfile) *runfile.RunCmd {
+	return a.buildCmd(r, map[string]string{}, false)
+}
+
+// GetCmdInfo fetches the metadata needed to register the command, without evaluating any of its values.
+// Fulfills runfile.CmdProvider#GetCmdInfo
+//
+func (a *Cmd) GetCmdInfo(r *runfile.Runfile) *runfile.CmdInfo {
+	info := &runfile.CmdInfo{
+		Flags:   a.Flags,
+		Name:    runfile.NamespacedName(a.Namespace, a.Name),
+		Runfile: a.Runfile,
+		Line:    a.Line,
+		Default: a.Config.Default,
+	}
+	info.Shell = (&runfile.RunCmd{Config: &runfile.RunCmdConfig{Shell: a.Config.Shell}, Script: a.Script, Scope: r.Scope}).Shell()
+	for _, alias := range a.Config.Aliases {
+		info.Aliases = append(info.Aliases, runfile.NamespacedName(a.Namespace, alias))
+	}
+	// Blank (static) lines are removed from the description, so do not count
+	//
+	for _, line := range a.Config.Desc {
+		if !isStaticValue(line) || len(strings.TrimSpace(line.Apply(r.Scope))) > 0 {
+			info.HasDesc = true
+			break
+		}
+	}
+	return info
+}
+
+// tryApply applies the node to the scope, skipping the node if it assigns a readonly variable.
+//
+func tryApply(node scopeNode, s *runfile.Scope) {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(*runfile.ReadonlyError); !ok {
+				panic(r)
+			}
+		}
+	}()
+	node.Apply(s)
+}
+
+// group evaluates the command's group.
+// Doc block GROUP takes precedence over .GROUP attribute,
+// Namespace used if neither defined
+//
+func (a *Cmd) group(s *runfile.Scope) string {
+	var group string
+	if a.Config.Group != nil {
+		group = strings.TrimSpace(a.Config.Group.Apply(s))
+	} else {
+		group = strings.TrimSpace(a.Group)
+	}
+	if len(group) == 0 {
+		group = a.Namespace
+	}
+	return group
+}
+
 // GetCmdEnv generates a Runfile command from the node and
 // a supplied starting env.
 // Provided env overrides the Runfile global env but not the command's env.
 // Fulfills runfile.CmdProvider#GetCmdEnv
 //
 func (a *Cmd) GetCmdEnv(r *runfile.Runfile, env map[string]string) *runfile.RunCmd {
+	return a.buildCmd(r, env, true)
+}
+
+// buildCmd generates a Runfile command from the node and a supplied starting env.
+// If strict is false, assignments to readonly variables within the doc block are skipped.
+//
+func (a *Cmd) buildCmd(r *runfile.Runfile, env map[string]string, strict bool) *runfile.RunCmd {
 	cmd := &runfile.RunCmd{
-		Flags:   a.Flags,
-		Name:    a.Name,
-		Scope:   runfile.NewScope(),
-		Script:  a.Script,
-		Runfile: a.Runfile,
-		Line:    a.Line,
+		Flags:     a.Flags,
+		Name:      runfile.NamespacedName(a.Namespace, a.Name),
+		Namespace: a.Namespace,
+		Scope:     r.Scope.Snapshot(), // Global vars and attrs - Values are shared, so are only evaluated once across commands
+		Script:    a.Script,
+		Runfile:   a.Runfile,
+		Line:      a.Line,
 	}
 	// Exports
 	//
@@ -543,50 +854,96 @@ func (a *Cmd) GetCmdEnv(r *runfile.Runfile, env map[string]string) *runfile.RunC
 	for _, export := range a.Config.AttrExports {
 		cmd.Scope.ExportAttr(export.AttrName, export.VarName)
 	}
-	// Vars
-	// Start with copy of global vars
-	//
-	for key, value := range r.Scope.Vars {
-		cmd.Scope.PutVar(key, value)
-	}
-	// Attrs
-	//
-	for key, value := range r.Scope.Attrs {
-		cmd.Scope.PutAttr(key, value)
-	}
 	// Provided Environment
+	// Readonly vars retain their value
 	//
 	for key, value := range env {
-		cmd.Scope.PutVar(key, value)
+		if !cmd.Scope.IsReadonly(key) {
+			cmd.Scope.PutVar(key, value)
+		}
 	}
 	// Config Environment
+	// Applied in the context of the command's Runfile, i.e. for error messages
 	//
+	currentRunfileBak := config.CurrentRunfile
+	config.CurrentRunfile = a.Runfile
 	for _, varAssignment := range a.Config.Vars {
-		varAssignment.Apply(cmd.Scope)
+		if strict {
+			varAssignment.Apply(cmd.Scope)
+		} else {
+			tryApply(varAssignment, cmd.Scope)
+		}
 	}
+	config.CurrentRunfile = currentRunfileBak
 	// Config
 	//
 	cmd.Config = &runfile.RunCmdConfig{}
 	// .SHELL
 	//
 	cmd.Config.Shell = a.Config.Shell
-	cmd.Scope.PutAttr(".SHELL", cmd.Shell())
-	// Config Desc
+	// Aliases - Namespaced along with the command name
 	//
-	for _, desc := range a.Config.Desc {
-		cmd.Config.Desc = append(cmd.Config.Desc, desc.Apply(cmd.Scope))
+	for _, alias := range a.Config.Aliases {
+		cmd.Config.Aliases = append(cmd.Config.Aliases, runfile.NamespacedName(a.Namespace, alias))
 	}
-	cmd.Config.Desc = runfile.NormalizeCmdDesc(cmd.Config.Desc)
-	// Config Usages
+	cmd.Config.Default = a.Config.Default
+	cmd.Scope.PutAttr(".SHELL", cmd.Shell())
+	// Group, Desc and Usages - Only evaluated when listing commands or showing help
 	//
-	for _, usage := range a.Config.Usages {
-		cmd.Config.Usages = append(cmd.Config.Usages, usage.Apply(cmd.Scope))
-	}
-	// Config Opts
+	scope := cmd.Scope.Snapshot()
+	cmd.Config.Group = runfile.NewLazyValue(func() string {
+		return a.group(scope)
+	})
+	cmd.Config.Desc = runfile.NewLazyList(func() []string {
+		var desc []string
+		for _, line := range a.Config.Desc {
+			desc = append(desc, line.Apply(scope))
+		}
+		return runfile.NormalizeCmdDesc(desc)
+	})
+	cmd.Config.Usages = runfile.NewLazyList(func() []string {
+		var usages []string
+		for _, usage := range a.Config.Usages {
+			usages = append(usages, usage.Apply(scope))
+		}
+		return usages
+	})
+	// Config Opts - Option sets first, then command's own
 	//
+	for _, setName := range a.Config.OptionSets {
+		set, ok := r.LookupOptionSet(a.Namespace, setName)
+		if !ok {
+			panic(fmt.Errorf("%s:%d: command %s: option set not found: %s", a.Runfile, a.Line, a.Name, setName))
+		}
+		for _, opt := range set.Opts {
+			cmd.Config.Opts = append(cmd.Config.Opts, opt.Apply(cmd))
+		}
+	}
 	for _, opt := range a.Config.Opts {
 		cmd.Config.Opts = append(cmd.Config.Opts, opt.Apply(cmd))
 	}
+	if err := runfile.CheckCmdOptConflicts(cmd.Config.Opts); err != nil {
+		panic(fmt.Errorf("%s:%d: command %s: %s", a.Runfile, a.Line, a.Name, err))
+	}
+	// Global Opts - Command's own options take precedence
+	//
+	// Only the command's own namespace and the top-level apply, with the namespace's options taking precedence.
+	//
+	namespaces := []string{""}
+	if len(a.Namespace) > 0 {
+		namespaces = []string{a.Namespace, ""}
+	}
+	for _, namespace := range namespaces {
+		for _, global := range r.GlobalOpts {
+			if !strings.EqualFold(global.Namespace, namespace) {
+				continue
+			}
+			if opt := global.Opt.Apply(cmd); !runfile.ShadowsCmdOpt(cmd.Config.Opts, opt) && !runfile.ShadowsCmdOpt(cmd.Config.GlobalOpts, opt) {
+				cmd.Config.GlobalOpts = append(cmd.Config.GlobalOpts, opt)
+			}
+		}
+	}
+
 	// Config 'Env' Runs
 	//
 	for _, cmdRun := range a.Config.EnvRuns {
@@ -602,6 +959,12 @@ func (a *Cmd) GetCmdEnv(r *runfile.Runfile, env map[string]string) *runfile.RunC
 	for _, cmdRun := range a.Config.AfterRuns {
 		cmd.Config.AfterRuns = append(cmd.Config.AfterRuns, cmdRun.Apply(cmd.Scope))
 	}
+	// Config Foreach
+	//
+	for _, foreach := range a.Config.Foreach {
+		cmd.Config.Foreach = append(cmd.Config.Foreach, foreach.Apply(cmd.Scope))
+	}
+	cmd.Config.Parallel = a.Config.Parallel
 	// Asserts - Global first, then Command
 	//
 	for _, assert := range r.Scope.Asserts {
@@ -617,9 +980,13 @@ func (a *Cmd) GetCmdEnv(r *runfile.Runfile, env map[string]string) *runfile.RunC
 //
 type CmdConfig struct {
 	Shell       string
+	Aliases     []string
+	Group       ScopeValueNode
+	Default     bool
 	Desc        []ScopeValueNode
 	Usages      []ScopeValueNode
 	Opts        []*CmdOpt
+	OptionSets  []string
 	Vars        []scopeNode
 	VarExports  []*ScopeVarExport
 	AttrExports []*ScopeAttrExport
@@ -627,34 +994,123 @@ type CmdConfig struct {
 	EnvRuns     []*CmdRun
 	BeforeRuns  []*CmdRun
 	AfterRuns   []*CmdRun
+	Foreach     []*CmdForeach
+	Parallel    bool
 }
 
-// CmdOpt wraps a command option.
+// OnlyOpts returns true if the config contains nothing but OPTION attributes (and desc lines).
 //
-type CmdOpt struct {
-	Name     string
-	Required bool
-	Default  ScopeValueNode
-	Short    rune
-	Long     string
-	Example  string
-	Desc     ScopeValueNode
+func (a *CmdConfig) OnlyOpts() bool {
+	return len(a.Shell) == 0 &&
+		len(a.Aliases) == 0 &&
+		a.Group == nil &&
+		!a.Default &&
+		len(a.Usages) == 0 &&
+		len(a.OptionSets) == 0 &&
+		len(a.Vars) == 0 &&
+		len(a.VarExports) == 0 &&
+		len(a.AttrExports) == 0 &&
+		len(a.Asserts) == 0 &&
+		len(a.EnvRuns) == 0 &&
+		len(a.BeforeRuns) == 0 &&
+		len(a.AfterRuns) == 0 &&
+		len(a.Foreach) == 0 &&
+		!a.Parallel
 }
 
-// Apply applies the node to the command.
+// OptionSet wraps a named set of options, shared across commands via '# OPTIONS <name>'.
 //
-func (a *CmdOpt) Apply(c *runfile.RunCmd) *runfile.RunCmdOpt {
-	opt := &runfile.RunCmdOpt{}
-	opt.Name = a.Name
-	opt.Required = a.Required
-	opt.HasDefault = a.Default != nil
+type OptionSet struct {
+	Name    string
+	Opts    []*CmdOpt
+	Runfile string
+	Line    int
+}
+
+// Apply applies the node to the runfile.
+//
+func (a *OptionSet) Apply(r *runfile.Runfile) {
+	name := strings.ToLower(a.Name) // Normalize
+	if len(config.CurrentNamespace) > 0 {
+		name = strings.ToLower(config.CurrentNamespace) + ":" + name
+	}
+	if existing, ok := r.OptionSets[name]; ok {
+		panic(fmt.Errorf("%s:%d: option set %s already defined in %s:%d", a.Runfile, a.Line, a.Name, existing.Runfile, existing.Line))
+	}
+	set := &runfile.OptionSet{Name: a.Name, Namespace: config.CurrentNamespace, Runfile: a.Runfile, Line: a.Line}
+	for _, opt := range a.Opts {
+		set.Opts = append(set.Opts, opt)
+	}
+	r.OptionSets[name] = set
+}
+
+// GlobalOpt wraps an option defined outside of a doc block, accepted by all commands within its namespace.
+//
+type GlobalOpt struct {
+	Opt     *CmdOpt
+	Runfile string
+	Line    int
+}
+
+// Apply applies the node to the runfile.
+//
+func (a *GlobalOpt) Apply(r *runfile.Runfile) {
+	for _, existing := range r.GlobalOpts {
+		// Options only conflict within a namespace
+		//
+		if !strings.EqualFold(existing.Namespace, config.CurrentNamespace) {
+			continue
+		}
+		var conflict string
+		switch {
+		case existing.Name == a.Opt.Name:
+			conflict = a.Opt.Name
+		case a.Opt.Short != 0 && existing.Short == a.Opt.Short:
+			conflict = "-" + string(a.Opt.Short)
+		case len(a.Opt.Long) > 0 && strings.EqualFold(existing.Long, a.Opt.Long):
+			conflict = "--" + strings.ToLower(a.Opt.Long)
+		default:
+			continue
+		}
+		panic(fmt.Errorf("%s:%d: global option %s conflicts with global option %s defined in %s:%d: %s", a.Runfile, a.Line, a.Opt.Name, existing.Name, existing.Runfile, existing.Line, conflict))
+	}
+	r.GlobalOpts = append(r.GlobalOpts, &runfile.GlobalOpt{
+		Opt:       a.Opt,
+		Name:      a.Opt.Name,
+		Short:     a.Opt.Short,
+		Long:      a.Opt.Long,
+		Namespace: config.CurrentNamespace,
+		Runfile:   a.Runfile,
+		Line:      a.Line,
+	})
+}
+
+// CmdOpt wraps a command option.
+//
+type CmdOpt struct {
+	Name     string
+	Required bool
+	Default  ScopeValueNode
+	Short    rune
+	Long     string
+	Example  string
+	Desc     ScopeValueNode
+}
+
+// Apply applies the node to the command.
+//
+func (a *CmdOpt) Apply(c *runfile.RunCmd) *runfile.RunCmdOpt {
+	opt := &runfile.RunCmdOpt{}
+	opt.Name = a.Name
+	opt.Required = a.Required
+	opt.HasDefault = a.Default != nil
 	if opt.HasDefault {
-		opt.Default = a.Default.Apply(c.Scope)
+		opt.Default = lazyValue(a.Default, c.Scope)
 	}
 	opt.Short = a.Short
 	opt.Long = a.Long
 	opt.Example = a.Example
-	opt.Desc = a.Desc.Apply(c.Scope)
+	opt.Desc = lazyValue(a.Desc, c.Scope)
 	return opt
 }
 
@@ -673,8 +1129,8 @@ func (a *CmdAssert) Apply(s *runfile.Scope) *runfile.Assert {
 	assert := &runfile.Assert{}
 	assert.Runfile = a.Runfile
 	assert.Line = a.Line
-	assert.Test = a.Test.Apply(s)
-	assert.Message = strings.TrimSpace(a.Message.Apply(s))
+	assert.Test = lazyValue(a.Test, s)
+	assert.Message = lazyValue(a.Message, s)
 	return assert
 }
 
@@ -683,6 +1139,7 @@ func (a *CmdAssert) Apply(s *runfile.Scope) *runfile.Assert {
 type CmdRun struct {
 	Command string
 	Args    []ScopeValueNode
+	Each    bool
 }
 
 // Apply applies the node to the Scope.
@@ -690,12 +1147,49 @@ type CmdRun struct {
 func (a *CmdRun) Apply(s *runfile.Scope) *runfile.RunCmdRun {
 	cmdRun := &runfile.RunCmdRun{}
 	cmdRun.Command = a.Command
+	cmdRun.Each = a.Each
 	for _, arg := range a.Args {
-		cmdRun.Args = append(cmdRun.Args, arg.Apply(s))
+		cmdRun.Args = append(cmdRun.Args, lazyValue(arg, s))
 	}
 	return cmdRun
 }
 
+// CmdForeach wraps a command FOREACH matrix variable.
+//
+type CmdForeach struct {
+	Name   string
+	Values []ScopeValueNode
+}
+
+// Apply applies the node to the Scope.
+//
+func (a *CmdForeach) Apply(s *runfile.Scope) *runfile.RunCmdForeach {
+	snapshot := s.Snapshot()
+	return &runfile.RunCmdForeach{
+		Name: a.Name,
+		Values: runfile.NewLazyList(func() []string {
+			var items []string
+			for _, value := range a.Values {
+				items = append(items, foreachItems(value, snapshot)...)
+			}
+			return items
+		}),
+	}
+}
+
+// foreachItems evaluates a FOREACH value as a list.
+// The output of (unquoted) substitutions is split on whitespace.
+//
+func foreachItems(node ScopeValueNode, s *runfile.Scope) []string {
+	if list, ok := node.(*ScopeValueNodeList); ok && len(list.Values) == 1 {
+		switch list.Values[0].(type) {
+		case *ScopeValueShell, *ScopeValueFn:
+			return strings.Fields(node.Apply(s))
+		}
+	}
+	return listItems(node, s)
+}
+
 // ScopeAttrAssignment wraps an attribute assignment.
 //
 type ScopeAttrAssignment struct {
@@ -712,36 +1206,287 @@ func (a *ScopeAttrAssignment) Apply(s *runfile.Scope) {
 // ScopeVarAssignment wraps a variable assignment.
 //
 type ScopeVarAssignment struct {
-	Name  string
-	Value ScopeValueNode
+	Name    string
+	Value   ScopeValueNode
+	Runfile string
+	Line    int
 }
 
 // Apply applies the node to the scope.
 //
 func (a *ScopeVarAssignment) Apply(s *runfile.Scope) {
-	s.PutVar(a.Name, a.Value.Apply(s))
+	s.CheckWritable(a.Name, fmt.Sprintf("%s:%d", a.Runfile, a.Line))
+	s.PutLazyVar(a.Name, lazyValue(a.Value, s))
+}
+
+// ScopeVarReadonlyAssignment wraps a readonly variable assignment ('::=').
+//
+type ScopeVarReadonlyAssignment struct {
+	Name    string
+	Value   ScopeValueNode
+	Runfile string
+	Line    int
+}
+
+// Apply applies the node to the scope.
+//
+func (a *ScopeVarReadonlyAssignment) Apply(s *runfile.Scope) {
+	where := fmt.Sprintf("%s:%d", a.Runfile, a.Line)
+	s.CheckWritable(a.Name, where)
+	s.PutReadonlyVar(a.Name, lazyValue(a.Value, s), where)
 }
 
 // ScopeVarQAssignment wraps a variable Q-Assignment.
 //
 type ScopeVarQAssignment struct {
-	Name  string
-	Value ScopeValueNode
+	Name    string
+	Value   ScopeValueNode
+	Runfile string
+	Line    int
 }
 
 // Apply applies the node to the scope.
 //
 func (a *ScopeVarQAssignment) Apply(s *runfile.Scope) {
-	// Only assign if not already present+non-empty
+	// Readonly vars are already assigned
 	//
-	if val, ok := s.GetVar(a.Name); !ok || len(val) == 0 {
+	if s.IsReadonly(a.Name) {
+		return
+	}
+	snapshot := s.Snapshot()
+	value := runfile.NewLazyValue(func() string {
+		// Only assign if not already present+non-empty
+		//
+		if val, ok := snapshot.GetVar(a.Name); ok && len(val) > 0 {
+			return val
+		}
 		// Use the Env value, if present+non-empty, else the assignment value
 		//
-		if val, ok = s.GetEnv(a.Name); !ok || len(val) == 0 {
-			val = a.Value.Apply(s)
+		if val, ok := snapshot.GetEnv(a.Name); ok && len(val) > 0 {
+			return val
+		}
+		return a.Value.Apply(snapshot)
+	})
+	assignDefaults(a.Value, s, snapshot, value)
+	s.PutLazyVar(a.Name, value)
+}
+
+// lazyValue defers evaluating the node until its value is needed.
+// The node is evaluated against a snapshot of the scope, as it exists now.
+// Static values are evaluated immediately, as they cannot depend on the scope.
+//
+func lazyValue(node ScopeValueNode, s *runfile.Scope) *runfile.LazyValue {
+	if list, ok := asListNode(node); ok {
+		if isStaticValue(node) {
+			return runfile.NewList(list.Items(s))
+		}
+		snapshot := s.Snapshot()
+		value := runfile.NewLazyList(func() []string {
+			return list.Items(snapshot)
+		})
+		assignDefaults(node, s, snapshot, value)
+		return value
+	}
+	if isStaticValue(node) {
+		return runfile.NewValue(node.Apply(s))
+	}
+	snapshot := s.Snapshot()
+	value := runfile.NewLazyValue(func() string {
+		return node.Apply(snapshot)
+	})
+	assignDefaults(node, s, snapshot, value)
+	return value
+}
+
+// assignDefaults assigns the variable of each '${NAME:=default}' reference within the node to the scope,
+// so references that follow the node see the assigned default.
+// The node is evaluated against a snapshot, so the variable is assigned lazily:
+// Its value is the value of the reference, once the node's value has been evaluated.
+//
+func assignDefaults(node ScopeValueNode, s *runfile.Scope, snapshot *runfile.Scope, value *runfile.LazyValue) {
+	for _, ref := range defaultAssignments(node) {
+		// Readonly variables are never re-assigned, attributes cannot be assigned
+		//
+		if s.IsReadonly(ref.Var.Name) || strings.HasPrefix(ref.Var.Name, ".") {
+			continue
+		}
+		ref := ref
+		s.PutLazyVar(ref.Var.Name, runfile.NewLazyValue(func() string {
+			value.Items()
+			val, _ := ref.Var.lookup(snapshot)
+			return val
+		}))
+	}
+}
+
+// defaultAssignments returns the '${NAME:=default}' (and '${NAME=default}') references within the node.
+//
+func defaultAssignments(node ScopeValueNode) []*ScopeValueVarDefault {
+	var refs []*ScopeValueVarDefault
+	var walk func(nodes ...ScopeValueNode)
+	walk = func(nodes ...ScopeValueNode) {
+		for _, node := range nodes {
+			switch n := node.(type) {
+			case *ScopeValueNodeList:
+				walk(n.Values...)
+			case *ScopeValueList:
+				walk(n.Values...)
+			case *ScopeBracketString:
+				walk(n.Value)
+			case *ScopeDBracketString:
+				walk(n.Value)
+			case *ScopeParenString:
+				walk(n.Value)
+			case *ScopeDParenString:
+				walk(n.Value)
+			case *ScopeValueVarDefault:
+				if strings.HasSuffix(n.Op, "=") {
+					refs = append(refs, n)
+				}
+				walk(n.Value)
+			case *ScopeValueVarError:
+				walk(n.Message)
+			case *ScopeValueVarAlt:
+				walk(n.Value)
+			case *ScopeValueVarSubstr:
+				walk(n.Range)
+			case *ScopeValueVarTrim:
+				walk(n.Pattern)
+			case *ScopeValueVarReplace:
+				walk(n.Pattern, n.Replacement)
+			case *ScopeValueShell:
+				walk(n.Cmd)
+			case *ScopeValueFn:
+				walk(n.Args...)
+			}
+		}
+	}
+	walk(node)
+	return refs
+}
+
+// listNode is implemented by nodes that evaluate to a list.
+//
+type listNode interface {
+	Items(s *runfile.Scope) []string
+}
+
+// asListNode returns the node as a list node, if it is one,
+// i.e. a list literal '[ ... ]' or a list reference '${NAME[@]}'.
+//
+func asListNode(node ScopeValueNode) (listNode, bool) {
+	switch n := node.(type) {
+	case *ScopeValueList:
+		return n, true
+	case *ScopeValueVar:
+		return n, n.Index == "@"
+	case *ScopeValueNodeList:
+		if len(n.Values) == 1 {
+			return asListNode(n.Values[0])
+		}
+	}
+	return nil, false
+}
+
+// listItems evaluates the node as a list.
+// Non-list nodes result in a single-item list.
+//
+func listItems(node ScopeValueNode, s *runfile.Scope) []string {
+	if list, ok := asListNode(node); ok {
+		return list.Items(s)
+	}
+	return []string{node.Apply(s)}
+}
+
+// isStaticValue returns true if the node does not reference any variables or shell substitutions.
+//
+func isStaticValue(node ScopeValueNode) bool {
+	switch n := node.(type) {
+	case *ScopeValueRunes, *ScopeValueEsc:
+		return true
+	case *ScopeValueNodeList:
+		for _, value := range n.Values {
+			if !isStaticValue(value) {
+				return false
+			}
 		}
-		s.PutVar(a.Name, val)
+		return true
+	case *ScopeValueList:
+		for _, value := range n.Values {
+			if !isStaticValue(value) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// ScopeVarAppend wraps a variable append ('+=').
+// Appending to a string appends the value, separated by a space.
+// Appending to (or appending) a list results in a list, containing the existing item(s) followed by the appended item(s).
+//
+type ScopeVarAppend struct {
+	Name    string
+	Value   ScopeValueNode
+	Runfile string
+	Line    int
+}
+
+// Apply applies the node to the scope.
+//
+func (a *ScopeVarAppend) Apply(s *runfile.Scope) {
+	s.CheckWritable(a.Name, fmt.Sprintf("%s:%d", a.Runfile, a.Line))
+	existing, hasExisting := s.GetLazyVar(a.Name)
+	snapshot := s.Snapshot()
+	_, valueIsList := asListNode(a.Value)
+	var value *runfile.LazyValue
+	if !valueIsList && (!hasExisting || !existing.IsList()) {
+		value = runfile.NewLazyValue(func() string {
+			var existingValue string
+			if hasExisting {
+				existingValue = existing.Get()
+			}
+			value := a.Value.Apply(snapshot)
+			if len(existingValue) > 0 && len(value) > 0 {
+				return existingValue + " " + value
+			}
+			return existingValue + value
+		})
+	} else {
+		value = runfile.NewLazyList(func() []string {
+			var items []string
+			if hasExisting {
+				items = append(items, existing.Items()...)
+			}
+			return append(items, listItems(a.Value, snapshot)...)
+		})
 	}
+	assignDefaults(a.Value, s, snapshot, value)
+	s.PutLazyVar(a.Name, value)
+}
+
+// ScopeValueList wraps a list literal, i.e. '[ a b "c d" ]'.
+//
+type ScopeValueList struct {
+	Values []ScopeValueNode
+}
+
+// Apply applies the node to the scope, returning the items joined with a space.
+//
+func (a *ScopeValueList) Apply(s *runfile.Scope) string {
+	return strings.Join(a.Items(s), " ")
+}
+
+// Items evaluates the items of the list.
+// List references (i.e. '${NAME[@]}') are spliced into the list.
+//
+func (a *ScopeValueList) Items(s *runfile.Scope) []string {
+	items := make([]string, 0, len(a.Values))
+	for _, value := range a.Values {
+		items = append(items, listItems(value, s)...)
+	}
+	return items
 }
 
 // ScopeValueRunes wraps a simple string as a value.
@@ -771,39 +1516,330 @@ func (a *ScopeValueEsc) Apply(_ *runfile.Scope) string {
 // ScopeValueVar wraps a variable reference.
 //
 type ScopeValueVar struct {
-	Name string
+	Name    string
+	Index   string // List index: '' | '@' (all items) | number
+	Runfile string
+	Line    int
 }
 
 // Apply applies the node to the scope, returning the value.
 //
 func (a *ScopeValueVar) Apply(s *runfile.Scope) string {
-	if val, ok := s.GetVar(a.Name); ok {
+	if val, ok := a.lookup(s); ok {
 		return val
 	}
+	a.undefined(s)
+	return ""
+}
+
+// Items evaluates the variable as a list.
+//
+func (a *ScopeValueVar) Items(s *runfile.Scope) []string {
+	if items, ok := a.lookupItems(s); ok {
+		return items
+	}
+	a.undefined(s)
+	return []string{}
+}
+
+// undefined records the reference to an undefined variable, failing if strict vars enabled.
+//
+func (a *ScopeValueVar) undefined(s *runfile.Scope) {
+	runfile.AddUndefinedVar(a.Name, a.Runfile, a.Line)
+	strictAttr, _ := s.GetAttr(".STRICT_VARS")
+	if (config.StrictVars || util.IsTrue(strictAttr)) && !config.CheckMode {
+		panic(fmt.Errorf("%s:%d: variable not defined: %s", a.Runfile, a.Line, a.Name))
+	}
+}
+
+// lookupItems fetches the value of the variable as a list.
+// Env and attribute values are treated as single-item lists.
+//
+func (a *ScopeValueVar) lookupItems(s *runfile.Scope) ([]string, bool) {
+	if items, ok := s.GetList(a.Name); ok {
+		return items, true
+	}
 	if val, ok := s.GetEnv(a.Name); ok {
-		return val
+		return []string{val}, true
+	}
+	if val, ok := s.GetAttr(a.Name); ok {
+		return []string{val}, true
+	}
+	return nil, false
+}
+
+// lookup fetches the value of the variable, checking vars, then env, then attrs.
+//
+func (a *ScopeValueVar) lookup(s *runfile.Scope) (string, bool) {
+	// List index
+	//
+	if len(a.Index) > 0 {
+		items, ok := a.lookupItems(s)
+		if !ok {
+			return "", false
+		}
+		if a.Index == "@" {
+			return strings.Join(items, " "), true
+		}
+		if i, err := strconv.Atoi(a.Index); err == nil && i < len(items) {
+			return items[i], true
+		}
+		return "", false
+	}
+	if val, ok := s.GetVar(a.Name); ok {
+		return val, true
+	}
+	if val, ok := s.GetEnv(a.Name); ok {
+		return val, true
 	}
 	if val, ok := s.GetAttr(a.Name); ok {
+		return val, true
+	}
+	return "", false
+}
+
+// isSet returns the value of the variable, along with whether it is considered set.
+// For ':' operators, empty values are considered unset.
+//
+func (a *ScopeValueVar) isSet(s *runfile.Scope, op string) (string, bool) {
+	val, ok := a.lookup(s)
+	if ok && strings.HasPrefix(op, ":") {
+		ok = len(val) > 0
+	}
+	return val, ok
+}
+
+// ScopeValueVarDefault wraps '${NAME:-default}' and '${NAME:=default}'.
+//
+type ScopeValueVarDefault struct {
+	Var   *ScopeValueVar
+	Op    string // '-' | ':-' | '=' | ':='
+	Value ScopeValueNode
+}
+
+// Apply applies the node to the scope, returning the value.
+//
+func (a *ScopeValueVarDefault) Apply(s *runfile.Scope) string {
+	if val, ok := a.Var.isSet(s, a.Op); ok {
 		return val
 	}
+	val := a.Value.Apply(s)
+	// Assign the default, so later references within the same value see it
+	// References in later assignments see it via assignDefaults
+	//
+	if strings.HasSuffix(a.Op, "=") {
+		s.PutVar(a.Var.Name, val)
+	}
+	return val
+}
+
+// ScopeValueVarError wraps '${NAME:?message}'.
+//
+type ScopeValueVarError struct {
+	Var     *ScopeValueVar
+	Op      string // '?' | ':?'
+	Message ScopeValueNode
+}
+
+// Apply applies the node to the scope, returning the value.
+//
+func (a *ScopeValueVarError) Apply(s *runfile.Scope) string {
+	if val, ok := a.Var.isSet(s, a.Op); ok {
+		return val
+	}
+	// Check mode reports the reference, instead of failing
+	//
+	if config.CheckMode {
+		runfile.AddUndefinedVar(a.Var.Name, a.Var.Runfile, a.Var.Line)
+		return ""
+	}
+	message := strings.TrimSpace(a.Message.Apply(s))
+	if len(message) == 0 {
+		message = "parameter null or not set"
+	}
+	panic(fmt.Errorf("%s:%d: %s: %s", a.Var.Runfile, a.Var.Line, a.Var.Name, message))
+}
+
+// ScopeValueVarAlt wraps '${NAME:+alternate}'.
+//
+type ScopeValueVarAlt struct {
+	Var   *ScopeValueVar
+	Op    string // '+' | ':+'
+	Value ScopeValueNode
+}
+
+// Apply applies the node to the scope, returning the value.
+//
+func (a *ScopeValueVarAlt) Apply(s *runfile.Scope) string {
+	if _, ok := a.Var.isSet(s, a.Op); ok {
+		return a.Value.Apply(s)
+	}
 	return ""
 }
 
-// ScopeValueShell wraps a command substitution string.
+// ScopeValueVarLength wraps '${#NAME}'.
 //
-type ScopeValueShell struct {
-	Cmd ScopeValueNode
+type ScopeValueVarLength struct {
+	Var *ScopeValueVar
 }
 
 // Apply applies the node to the scope, returning the value.
 //
-func (a *ScopeValueShell) Apply(s *runfile.Scope) string {
-	cmd := a.Cmd.Apply(s)
+func (a *ScopeValueVarLength) Apply(s *runfile.Scope) string {
+	// Number of items, i.e. '${#NAME[@]}'
+	//
+	if a.Var.Index == "@" {
+		return strconv.Itoa(len(a.Var.Items(s)))
+	}
+	return strconv.Itoa(utf8.RuneCountInString(a.Var.Apply(s)))
+}
+
+// ScopeValueVarSubstr wraps '${NAME:offset}' and '${NAME:offset:length}'.
+// Negative values count back from the end of the value.
+//
+type ScopeValueVarSubstr struct {
+	Var   *ScopeValueVar
+	Range ScopeValueNode
+}
+
+// Apply applies the node to the scope, returning the value.
+//
+func (a *ScopeValueVarSubstr) Apply(s *runfile.Scope) string {
+	value := []rune(a.Var.Apply(s))
+	rangeStr := a.Range.Apply(s)
+	parts := strings.SplitN(rangeStr, ":", 2)
+	offset, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		panic(fmt.Errorf("%s:%d: %s: invalid substring offset: '%s'", a.Var.Runfile, a.Var.Line, a.Var.Name, parts[0]))
+	}
+	if offset < 0 {
+		offset += len(value)
+	}
+	if offset < 0 || offset > len(value) {
+		return ""
+	}
+	end := len(value)
+	if len(parts) > 1 {
+		length, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil {
+			panic(fmt.Errorf("%s:%d: %s: invalid substring length: '%s'", a.Var.Runfile, a.Var.Line, a.Var.Name, parts[1]))
+		}
+		if length < 0 {
+			end += length
+		} else if offset+length < end {
+			end = offset + length
+		}
+	}
+	if end < offset {
+		panic(fmt.Errorf("%s:%d: %s: substring expression < 0: '%s'", a.Var.Runfile, a.Var.Line, a.Var.Name, rangeStr))
+	}
+	return string(value[offset:end])
+}
+
+// ScopeValueVarTrim wraps '${NAME#pattern}', '${NAME##pattern}', '${NAME%pattern}' and '${NAME%%pattern}'.
+//
+type ScopeValueVarTrim struct {
+	Var     *ScopeValueVar
+	Op      string // '#' | '##' | '%' | '%%'
+	Pattern ScopeValueNode
+}
+
+// Apply applies the node to the scope, returning the value.
+//
+func (a *ScopeValueVarTrim) Apply(s *runfile.Scope) string {
+	value := []rune(a.Var.Apply(s))
+	pattern := compileGlob(a.Var, a.Pattern.Apply(s))
+	match := func(r []rune) bool { return pattern.MatchString(string(r)) }
+	switch a.Op {
+	case "#": // Shortest prefix
+		for i := 0; i <= len(value); i++ {
+			if match(value[:i]) {
+				return string(value[i:])
+			}
+		}
+	case "##": // Longest prefix
+		for i := len(value); i >= 0; i-- {
+			if match(value[:i]) {
+				return string(value[i:])
+			}
+		}
+	case "%": // Shortest suffix
+		for i := len(value); i >= 0; i-- {
+			if match(value[i:]) {
+				return string(value[:i])
+			}
+		}
+	default: // "%%" Longest suffix
+		for i := 0; i <= len(value); i++ {
+			if match(value[i:]) {
+				return string(value[:i])
+			}
+		}
+	}
+	return string(value)
+}
+
+// ScopeValueVarReplace wraps '${NAME/pattern/replacement}' and '${NAME//pattern/replacement}'.
+//
+type ScopeValueVarReplace struct {
+	Var         *ScopeValueVar
+	Op          string // '/' (first match) | '//' (all matches)
+	Pattern     ScopeValueNode
+	Replacement ScopeValueNode
+}
+
+// Apply applies the node to the scope, returning the value.
+//
+func (a *ScopeValueVarReplace) Apply(s *runfile.Scope) string {
+	value := []rune(a.Var.Apply(s))
+	patternStr := a.Pattern.Apply(s)
+	if len(patternStr) == 0 {
+		return string(value)
+	}
+	pattern := compileGlob(a.Var, patternStr)
+	replacement := a.Replacement.Apply(s)
+	b := &strings.Builder{}
+	replaced := false
+	for start := 0; start < len(value); {
+		// Longest match at the current position
+		//
+		end := -1
+		if !replaced || a.Op == "//" {
+			for i := len(value); i > start; i-- {
+				if pattern.MatchString(string(value[start:i])) {
+					end = i
+					break
+				}
+			}
+		}
+		if end < 0 {
+			b.WriteRune(value[start])
+			start++
+			continue
+		}
+		b.WriteString(replacement)
+		replaced = true
+		start = end
+	}
+	return b.String()
+}
+
+// compileGlob compiles a shell pattern, panicking if the pattern is invalid.
+//
+func compileGlob(ref *ScopeValueVar, pattern string) *regexp.Regexp {
+	re, err := util.CompileGlob(pattern)
+	if err != nil {
+		panic(fmt.Errorf("%s:%d: %s: invalid pattern '%s': %s", ref.Runfile, ref.Line, ref.Name, pattern, err))
+	}
+	return re
+}
+
+// exportEnv builds the environment for a sub-shell, from the exported variables and attributes of the scope.
+//
+func exportEnv(s *runfile.Scope) map[string]string {
 	env := make(map[string]string)
 	for _, export := range s.GetVarExports() {
-		if value, ok := s.GetVar(export.VarName); ok {
-			env[export.VarName] = value
-		} else {
+		if !s.AddVarToEnv(env, export.VarName) {
 			log.Printf("WARNING: exported variable not defined: '%s'", export.VarName)
 		}
 	}
@@ -814,18 +1850,79 @@ func (a *ScopeValueShell) Apply(s *runfile.Scope) string {
 			log.Printf("WARNING: exported attribute not defined: '%s'", export.AttrName)
 		}
 	}
-	capturedOutput := &strings.Builder{}
+	return env
+}
+
+// scopeShell returns the global shell of the scope, i.e. the .SHELL attribute.
+//
+func scopeShell(s *runfile.Scope) string {
 	shell, ok := s.GetAttr(".SHELL")
 	if !ok || len(shell) == 0 {
 		shell = config.DefaultShell
 	}
-	exec.ExecuteSubCommand(shell, cmd, env, capturedOutput)
+	return shell
+}
+
+// ScopeValueShell wraps a command substitution string.
+//
+type ScopeValueShell struct {
+	Runfile   string
+	Line      int
+	Cmd       ScopeValueNode
+	JoinLines bool // Replace newlines with spaces, i.e. for shell assignments ('!=')
+}
+
+// Apply applies the node to the scope, returning the value.
+//
+func (a *ScopeValueShell) Apply(s *runfile.Scope) string {
+	// Check mode is static - Don't invoke any shells
+	//
+	if config.CheckMode {
+		return ""
+	}
+	cmd := a.Cmd.Apply(s)
+	env := exportEnv(s)
+	capturedOutput := &strings.Builder{}
+	shell := scopeShell(s)
+	strictAttr, _ := s.GetAttr(".SHELL.STRICT")
+	strict := config.StrictShell || util.IsTrue(strictAttr)
+	captureAttr, _ := s.GetAttr(".SHELL.CAPTURE_STDERR")
+	capture := util.IsTrue(captureAttr)
+	// Stderr is either captured into the result,
+	// or buffered in strict mode so we can report it on failure
+	//
+	capturedErr := &strings.Builder{}
+	var errOut io.Writer = os.Stderr
+	if capture {
+		errOut = capturedOutput
+	} else if strict {
+		errOut = capturedErr
+	}
+	exitCode := exec.ExecuteSubCommand(shell, cmd, env, capturedOutput, errOut)
 	result := capturedOutput.String()
+	if strict {
+		if exitCode != 0 {
+			stderr := capturedErr.String()
+			if capture {
+				stderr = result
+			}
+			if stderr = strings.TrimSpace(stderr); len(stderr) > 0 {
+				panic(fmt.Errorf("%s:%d: command substitution failed (exit code %d):\n%s", a.Runfile, a.Line, exitCode, stderr))
+			}
+			panic(fmt.Errorf("%s:%d: command substitution failed (exit code %d)", a.Runfile, a.Line, exitCode))
+		}
+		// Succeeded - Forward the buffered stderr
+		//
+		_, _ = os.Stderr.WriteString(capturedErr.String())
+	}
 
 	// Trim trailing newlines, per std command-substitution behavior
 	//
-	for result[len(result)-1] == '\n' {
+	for len(result) > 0 && result[len(result)-1] == '\n' {
 		result = result[0 : len(result)-1]
 	}
+	if a.JoinLines {
+		result = strings.ReplaceAll(result, "\n", " ")
+	}
 	return result
 }
@@ -0,0 +1,435 @@
+package ast_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tekwizely/run/internal/ast"
+	"github.com/tekwizely/run/internal/config"
+	"github.com/tekwizely/run/internal/parser"
+	"github.com/tekwizely/run/internal/runfile"
+)
+
+// processRunfile parses and processes the runfile source.
+//
+func processRunfile(src string) *runfile.Runfile {
+	return ast.ProcessAST(parser.ParseBytes([]byte(src)))
+}
+
+// varTest is a runfile source, along with the expected values of its variables.
+// Variables are evaluated in the order listed.
+//
+type varTest struct {
+	name string
+	src  string
+	want [][2]string // { name, value }
+}
+
+// runVarTests checks each source evaluates its variables as expected.
+//
+func runVarTests(t *testing.T, tests []varTest) {
+	t.Helper()
+	for _, test := range tests {
+		rf := processRunfile(test.src)
+		for _, want := range test.want {
+			if got, _ := rf.Scope.GetVar(want[0]); got != want[1] {
+				t.Errorf("%s: %s = %q, want %q", test.name, want[0], got, want[1])
+			}
+		}
+	}
+}
+
+func TestVarDefaultAssignment(t *testing.T) {
+	runVarTests(t, []varTest{
+		{"later reference", "A := ${X:=foo}\nB := \"${X}\"\n", [][2]string{{"B", "foo"}, {"A", "foo"}, {"X", "foo"}}},
+		{"later reference, evaluated last", "A := ${X:=foo}\nB := \"${X}\"\n", [][2]string{{"A", "foo"}, {"X", "foo"}, {"B", "foo"}}},
+		{"same value", "A := \"${X:=foo}-${X}\"\nB := ${X}\n", [][2]string{{"A", "foo-foo"}, {"B", "foo"}}},
+		{"already set", "X := bar\nA := ${X:=foo}\nB := ${X}\n", [][2]string{{"A", "bar"}, {"B", "bar"}}},
+		{"empty", "X := \"\"\nA := ${X:=foo}\nB := ${X}\n", [][2]string{{"A", "foo"}, {"B", "foo"}}},
+		{"empty, no colon", "X := \"\"\nA := ${X=foo}\nB := ${X}\n", [][2]string{{"A", ""}, {"B", ""}}},
+		{"nested", "A := ${Y:-${X:=foo}}\nB := ${X}\n", [][2]string{{"B", "foo"}}},
+		{"no assignment", "A := ${X:-foo}\nB := \"${X}\"\n", [][2]string{{"A", "foo"}, {"B", ""}}},
+		{"append", "A := a\nA += ${X:=foo}\nB := ${X}\n", [][2]string{{"B", "foo"}, {"A", "a foo"}}},
+		{"earlier reference", "B := \"${X}\"\nA := ${X:=foo}\n", [][2]string{{"B", ""}, {"A", "foo"}}},
+	})
+}
+
+func TestFunctionPaths(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.txt", "src/b.go", "src/c.go"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte("abc"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runfileAbsDir := config.RunfileAbsDir
+	t.Cleanup(func() { config.RunfileAbsDir = runfileAbsDir })
+	config.RunfileAbsDir = dir
+	// Relative paths resolve against the Runfile dir, not the current dir
+	//
+	runVarTests(t, []varTest{
+		{"abspath", "A := $(fn:abspath src/b.go)\n", [][2]string{{"A", filepath.Join(dir, "src", "b.go")}}},
+		{"exists", "A := $(fn:exists a.txt)\nB := $(fn:exists nope.txt)\n", [][2]string{{"A", "true"}, {"B", ""}}},
+		{"glob", "A := $(fn:glob src/*.go)\n", [][2]string{{"A", filepath.Join("src", "b.go") + " " + filepath.Join("src", "c.go")}}},
+		{"glob absolute", "A := $(fn:glob " + filepath.Join(dir, "*.txt") + ")\n", [][2]string{{"A", filepath.Join(dir, "a.txt")}}},
+		{"sha256file", "A := $(fn:sha256file a.txt)\n", [][2]string{{"A", "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad"}}},
+	})
+}
+
+func TestLists(t *testing.T) {
+	runVarTests(t, []varTest{
+		{"list", "A := [ a \"b c\" d ]\nB := ${A[1]}\nC := ${#A[@]}\n", [][2]string{{"A", "a b c d"}, {"B", "b c"}, {"C", "3"}}},
+		{"empty list", "A := [ ]\nB := ${#A[@]}\n", [][2]string{{"A", ""}, {"B", "0"}}},
+		{"no space is a string", "A := [abc]\nB := ${#A[@]}\n", [][2]string{{"A", "[abc]"}, {"B", "1"}}},
+		{"no space, quoted item is a string", "A := [\"a\"]\n", [][2]string{{"A", "[\"a\"]"}}},
+		{"append item", "A := [ a b ]\nA += c\nB := ${A[2]}\n", [][2]string{{"A", "a b c"}, {"B", "c"}}},
+		{"append list", "A := a\nA += [ b c ]\nB := ${#A[@]}\n", [][2]string{{"A", "a b c"}, {"B", "3"}}},
+		{"append string", "A := a\nA += b\nB := ${#A[@]}\n", [][2]string{{"A", "a b"}, {"B", "1"}}},
+		{"splice", "A := [ a b ]\nB := [ ${A[@]} c ]\nC := ${#B[@]}\n", [][2]string{{"C", "3"}}},
+		{"index out of range", "A := [ a ]\nB := ${A[3]}\n", [][2]string{{"B", ""}}},
+		{"escapes", "A := [ a\\ b c\\] ]\nB := ${A[0]}\nC := ${A[1]}\n", [][2]string{{"B", "a b"}, {"C", "c]"}}},
+		{"index operator", "A := [ a ]\nB := ${A[0]:-x}\nC := ${A[1]:-x}\n", [][2]string{{"B", "a"}, {"C", "x"}}},
+		{"index in string", "A := [ a b ]\nB := \"${A[1]}-${#A[@]}\"\n", [][2]string{{"B", "b-2"}}},
+	})
+}
+
+// tryProcess calls fn, returning the error it panics with, if any.
+//
+func tryProcess(fn func()) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	fn()
+	return nil
+}
+
+// setRunfile sets the current runfile name, restoring the previous name when the test completes.
+//
+func setRunfile(t *testing.T, name string) {
+	currentRunfile := config.CurrentRunfile
+	t.Cleanup(func() { config.CurrentRunfile = currentRunfile })
+	config.CurrentRunfile = name
+}
+
+func TestReadonlyVars(t *testing.T) {
+	setRunfile(t, "Runfile")
+	runVarTests(t, []varTest{
+		{"readonly", "A ::= a\nB := ${A}\n", [][2]string{{"A", "a"}, {"B", "a"}}},
+		{"conditional assignment ignored", "A ::= a\nA ?= b\n", [][2]string{{"A", "a"}}},
+	})
+	tests := []struct {
+		name    string
+		src     string
+		wantErr string
+	}{
+		{"assignment", "A ::= a\nB := b\nA := c\n", "Runfile:3: cannot assign readonly variable 'A' (defined at Runfile:1)"},
+		{"append", "A ::= a\n\nA += c\n", "Runfile:3: cannot assign readonly variable 'A' (defined at Runfile:1)"},
+		{"readonly assignment", "A ::= a\nA ::= c\n", "Runfile:2: cannot assign readonly variable 'A' (defined at Runfile:1)"},
+		{"shell assignment", "A ::= a\nA != echo c\n", "Runfile:2: cannot assign readonly variable 'A' (defined at Runfile:1)"},
+		{"export assignment", "A ::= a\nEXPORT A := c\n", "Runfile:2: cannot assign readonly variable 'A' (defined at Runfile:1)"},
+	}
+	for _, test := range tests {
+		err := tryProcess(func() { processRunfile(test.src) })
+		if err == nil || err.Error() != test.wantErr {
+			t.Errorf("%s: got error %v, want %q", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestReadonlyVarsDocBlock(t *testing.T) {
+	setRunfile(t, "Runfile")
+	rf := processRunfile("EXPORT A ::= a\n\n##\n# Bad command\n# EXPORT A := b\nbad:\n  echo\n\n##\n# Good command\ngood:\n  echo\n")
+	bad, good := rf.Cmds[0], rf.Cmds[1]
+	// Only reported for the command being built
+	//
+	if title := bad.DescribeCmd(rf).Title(); title != "Bad command" {
+		t.Errorf("DescribeCmd(bad).Title() = %q, want %q", title, "Bad command")
+	}
+	if err := tryProcess(func() { good.GetCmd(rf) }); err != nil {
+		t.Errorf("GetCmd(good): unexpected error: %v", err)
+	}
+	wantErr := "Runfile:5: cannot assign readonly variable 'A' (defined at Runfile:1)"
+	if err := tryProcess(func() { bad.GetCmd(rf) }); err == nil || err.Error() != wantErr {
+		t.Errorf("GetCmd(bad): got error %v, want %q", err, wantErr)
+	}
+}
+
+func TestReadonlyVarsOverride(t *testing.T) {
+	setRunfile(t, "Runfile")
+	varOverrides := config.VarOverrides
+	t.Cleanup(func() { config.VarOverrides = varOverrides })
+	config.VarOverrides = map[string]string{"B": "x"}
+	runVarTests(t, []varTest{
+		{"override", "A ::= a\nB := b\n", [][2]string{{"A", "a"}, {"B", "x"}}},
+	})
+	wantErr := "Runfile:2: readonly variable 'B' cannot be overridden from the command line"
+	if err := tryProcess(func() { processRunfile("A := a\nB ::= b\n") }); err == nil || err.Error() != wantErr {
+		t.Errorf("got error %v, want %q", err, wantErr)
+	}
+}
+
+func TestIfBlocks(t *testing.T) {
+	runVarTests(t, []varTest{
+		{"equal", "A := dev\nIF ${A} == dev\nB := yes\nELSE\nB := no\nEND\n", [][2]string{{"B", "yes"}}},
+		{"not equal", "A := dev\nIF ${A} != dev\nB := yes\nELSE\nB := no\nEND\n", [][2]string{{"B", "no"}}},
+		{"compound", "A := dev\nIF ${A}-x == dev-x\nB := yes\nEND\n", [][2]string{{"B", "yes"}}},
+		{"compound substitution", "A := dev\nIF x$(echo y)${A} == \"xy\"'dev'\nB := yes\nEND\n", [][2]string{{"B", "yes"}}},
+		{"no space around operator", "A := dev\nIF ${A}==dev\nB := yes\nEND\n", [][2]string{{"B", "yes"}}},
+		{"quoted", "A := \"a b\"\nIF \"${A}\" == 'a b'\nB := yes\nEND\n", [][2]string{{"B", "yes"}}},
+		{"empty", "IF ${A:-} ==\nB := yes\nEND\n", [][2]string{{"B", "yes"}}},
+		{"nested", "A := 1\nIF ${A} == 1\nIF ${A} == 2\nB := no\nELSE\nB := yes\nEND\nEND\n", [][2]string{{"B", "yes"}}},
+		{"test", "IF ( true )\nB := yes\nEND\n", [][2]string{{"B", "yes"}}},
+	})
+}
+
+func TestAssignmentOperators(t *testing.T) {
+	runVarTests(t, []varTest{
+		{"append", "A := a\nA += b\n", [][2]string{{"A", "a b"}}},
+		{"append undefined", "A += b\n", [][2]string{{"A", "b"}}},
+		{"append empty", "A := a\nA += \"\"\n", [][2]string{{"A", "a"}}},
+		{"append evaluated in order", "B := 1\nA := ${B}\nB := 2\nA += ${B}\n", [][2]string{{"A", "1 2"}}},
+		{"shell", "A != printf 'a\\nb\\n'\n", [][2]string{{"A", "a b"}}},
+		{"shell with var", "B := x\nA != echo ${B}-$(fn:upper y)\n", [][2]string{{"A", "x-Y"}}},
+		{"shell quotes passed as-is", "A != echo \"a  b\"\n", [][2]string{{"A", "a  b"}}},
+		{"shell lazy", "A != echo a\nB := ${A}\n", [][2]string{{"B", "a"}}},
+	})
+}
+
+func TestFunctions(t *testing.T) {
+	t.Setenv("RUN_TEST_FN_ENV", "env")
+	runVarTests(t, []varTest{
+		{"upper", "A := $(fn:upper abc)\n", [][2]string{{"A", "ABC"}}},
+		{"lower", "A := $(fn:lower ABC)\n", [][2]string{{"A", "abc"}}},
+		{"replace", "A := $(fn:replace a b aXa)\n", [][2]string{{"A", "bXb"}}},
+		{"join", "A := $(fn:join , a b c)\n", [][2]string{{"A", "a,b,c"}}},
+		{"basename", "A := $(fn:basename dir/file.txt .txt)\n", [][2]string{{"A", "file"}}},
+		{"dirname", "A := $(fn:dirname dir/file.txt)\n", [][2]string{{"A", "dir"}}},
+		{"nested", "B := dir/file.txt\nA := $(fn:upper $(fn:basename ${B} .txt))\n", [][2]string{{"A", "FILE"}}},
+		{"quoted arg", "A := $(fn:upper \"a b\")\n", [][2]string{{"A", "A B"}}},
+		{"env", "A := $(fn:env RUN_TEST_FN_ENV)\nB := $(fn:env RUN_TEST_FN_NOPE def)\n", [][2]string{{"A", "env"}, {"B", "def"}}},
+		{"unknown function is a shell substitution", "A := $(fn:nope 2>/dev/null || echo shell)\n", [][2]string{{"A", "shell"}}},
+	})
+}
+
+func TestDocBlockDescKeywords(t *testing.T) {
+	rf := processRunfile("##\n# Group related targets.\n# Default behaviour is to build everything.\n# Foreach target, run the linter.\n# GROUP Build\n# DEFAULT\nbuild:\n  echo\n")
+	cmd := rf.Cmds[0].GetCmd(rf)
+	want := "Group related targets.|Default behaviour is to build everything.|Foreach target, run the linter."
+	if got := strings.Join(cmd.Config.Desc.Items(), "|"); got != want {
+		t.Errorf("desc = %q, want %q", got, want)
+	}
+	if got := cmd.Config.Group.Get(); got != "Build" {
+		t.Errorf("group = %q, want %q", got, "Build")
+	}
+	if !cmd.Config.Default {
+		t.Errorf("default = false, want true")
+	}
+}
+
+// processIncludes writes the included runfiles to a temp dir, then parses and processes the runfile source,
+// which is treated as a Runfile within the same dir.
+//
+func processIncludes(t *testing.T, files map[string]string, src string) *runfile.Runfile {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filename, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	parseBytes := ast.ParseBytes
+	runfileAbsDir := config.RunfileAbsDir
+	includeCycleMap := config.IncludeCycleMap
+	t.Cleanup(func() {
+		ast.ParseBytes = parseBytes
+		config.RunfileAbsDir = runfileAbsDir
+		config.IncludeCycleMap = includeCycleMap
+	})
+	ast.ParseBytes = parser.ParseBytes
+	config.RunfileAbsDir = dir
+	config.IncludeCycleMap = map[string]struct{}{}
+	setRunfile(t, "Runfile")
+	return processRunfile(src)
+}
+
+// cmdOptNames returns the names of the command's own (non-global) options, keyed by command name.
+//
+func cmdOptNames(rf *runfile.Runfile) map[string]string {
+	names := map[string]string{}
+	for _, provider := range rf.Cmds {
+		cmd := provider.GetCmd(rf)
+		var opts []string
+		for _, opt := range cmd.Config.Opts {
+			opts = append(opts, opt.Name)
+		}
+		names[cmd.Name] = strings.Join(opts, ",")
+	}
+	return names
+}
+
+func TestOptionSetNamespaces(t *testing.T) {
+	files := map[string]string{
+		"a/Runfile": "##\n# OPTION A_OPT --a-opt\nOPTIONS common\n\n##\n# OPTIONS common\nhi:\n  echo\n",
+		"b/Runfile": "##\n# OPTION B_OPT --b-opt\nOPTIONS common\n\n##\n# OPTIONS common\nbye:\n  echo\n\n##\n# OPTIONS root\nroot:\n  echo\n",
+	}
+	src := "##\n# OPTION ROOT_OPT --root-opt\nOPTIONS root\n\n##\n# OPTION TOP_OPT --top-opt\nOPTIONS common\n\n##\n# OPTIONS common\ntop:\n  echo\n\nINCLUDE a/Runfile AS a\nINCLUDE b/Runfile AS b\n"
+	names := cmdOptNames(processIncludes(t, files, src))
+	for cmd, want := range map[string]string{"top": "TOP_OPT", "a:hi": "A_OPT", "b:bye": "B_OPT", "b:root": "ROOT_OPT"} {
+		if got := names[cmd]; got != want {
+			t.Errorf("%s: options = %q, want %q", cmd, got, want)
+		}
+	}
+}
+
+func TestOptionSetNamespacesDuplicate(t *testing.T) {
+	files := map[string]string{
+		"a/Runfile": "##\n# OPTION A_OPT --a-opt\nOPTIONS common\n\n##\n# OPTION A_OPT2 --a-opt2\nOPTIONS common\n",
+	}
+	err := tryProcess(func() { processIncludes(t, files, "INCLUDE a/Runfile AS a\n") })
+	if err == nil || !strings.Contains(err.Error(), "option set common already defined in") {
+		t.Errorf("err = %v, want option set already defined", err)
+	}
+}
+
+// cmdGlobalOptNames returns the names of the command's global options, keyed by command name.
+//
+func cmdGlobalOptNames(rf *runfile.Runfile) map[string]string {
+	names := map[string]string{}
+	for _, provider := range rf.Cmds {
+		cmd := provider.GetCmd(rf)
+		var opts []string
+		for _, opt := range cmd.Config.GlobalOpts {
+			opts = append(opts, opt.Name)
+		}
+		names[cmd.Name] = strings.Join(opts, ",")
+	}
+	return names
+}
+
+func TestGlobalOptNamespaces(t *testing.T) {
+	files := map[string]string{
+		"a/Runfile": "OPTION VERBOSE -v,--verbose\nOPTION A_OPT --a-opt\nhi:\n  echo\n",
+		"b/Runfile": "OPTION VERBOSE -v,--verbose\nOPTION TOP -t,--top\nbye:\n  echo\n",
+	}
+	src := "OPTION TOP -t,--top\nOPTION ROOT_OPT --root-opt\ntop:\n  echo\n\nINCLUDE a/Runfile AS a\nINCLUDE b/Runfile AS b\n"
+	names := cmdGlobalOptNames(processIncludes(t, files, src))
+	for cmd, want := range map[string]string{"top": "TOP,ROOT_OPT", "a:hi": "VERBOSE,A_OPT,TOP,ROOT_OPT", "b:bye": "VERBOSE,TOP,ROOT_OPT"} {
+		if got := names[cmd]; got != want {
+			t.Errorf("%s: global options = %q, want %q", cmd, got, want)
+		}
+	}
+}
+
+func TestGlobalOptNamespacesConflict(t *testing.T) {
+	files := map[string]string{
+		"a/Runfile": "OPTION VERBOSE -v,--verbose\nOPTION VERBOSE2 -v\n",
+	}
+	err := tryProcess(func() { processIncludes(t, files, "INCLUDE a/Runfile AS a\n") })
+	if err == nil || !strings.Contains(err.Error(), "global option VERBOSE2 conflicts with global option VERBOSE") {
+		t.Errorf("err = %v, want global option conflict", err)
+	}
+}
+
+func TestLazyEvaluation(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "log")
+	readLog := func() string {
+		content, _ := ioutil.ReadFile(log)
+		return string(content)
+	}
+	rf := processRunfile("B := b\n" +
+		"A := \"${B}-$(echo a >> " + log + "; echo a)\"\n" +
+		"B := c\n" +
+		"C := $(echo c >> " + log + "; echo c)\n")
+	// Nothing is evaluated when the Runfile is loaded
+	//
+	if got := readLog(); got != "" {
+		t.Errorf("after load: log = %q, want %q", got, "")
+	}
+	// Values are evaluated against the variables as they were at the point of assignment
+	//
+	if got, _ := rf.Scope.GetVar("A"); got != "b-a" {
+		t.Errorf("A = %q, want %q", got, "b-a")
+	}
+	// Values are only evaluated once, and only when needed
+	//
+	if got, _ := rf.Scope.GetVar("A"); got != "b-a" {
+		t.Errorf("A (again) = %q, want %q", got, "b-a")
+	}
+	if got := readLog(); got != "a\n" {
+		t.Errorf("after A: log = %q, want %q", got, "a\n")
+	}
+	if got, _ := rf.Scope.GetVar("C"); got != "c" {
+		t.Errorf("C = %q, want %q", got, "c")
+	}
+	if got := readLog(); got != "a\nc\n" {
+		t.Errorf("after C: log = %q, want %q", got, "a\nc\n")
+	}
+}
+
+func TestLazyEvaluationCmd(t *testing.T) {
+	log := filepath.Join(t.TempDir(), "log")
+	readLog := func() string {
+		content, _ := ioutil.ReadFile(log)
+		return string(content)
+	}
+	rf := processRunfile("A := $(echo a >> " + log + "; echo a)\n" +
+		"B := $(echo b >> " + log + "; echo b)\n" +
+		"D := $(echo desc >> " + log + "; echo desc)\n" +
+		"##\n# Desc ${D}\n# EXPORT A\nbuild:\n  echo\n")
+	cmd := rf.Cmds[0].GetCmd(rf)
+	if got := readLog(); got != "" {
+		t.Errorf("after GetCmd: log = %q, want %q", got, "")
+	}
+	// Descriptions are only evaluated when displayed
+	//
+	if got := strings.Join(cmd.Config.Desc.Items(), "|"); got != "Desc desc" {
+		t.Errorf("desc = %q, want %q", got, "Desc desc")
+	}
+	if got := readLog(); got != "desc\n" {
+		t.Errorf("after desc: log = %q, want %q", got, "desc\n")
+	}
+	// Only the values the command uses are evaluated
+	//
+	if got, _ := cmd.Scope.GetVar("A"); got != "a" {
+		t.Errorf("A = %q, want %q", got, "a")
+	}
+	if got := readLog(); got != "desc\na\n" {
+		t.Errorf("after A: log = %q, want %q", got, "desc\na\n")
+	}
+}
+
+func TestStrictShellSubstitution(t *testing.T) {
+	setRunfile(t, "Runfile")
+	runVarTests(t, []varTest{
+		{"failure ignored", "A := $(echo a; exit 3)\n", [][2]string{{"A", "a"}}},
+		{"empty output", "A := $(true)\n", [][2]string{{"A", ""}}},
+		{"strict success", ".SHELL.STRICT = true\nA := $(echo a)\n", [][2]string{{"A", "a"}}},
+		{"capture stderr", ".SHELL.CAPTURE_STDERR = true\nA := $(echo err >&2)\n", [][2]string{{"A", "err"}}},
+	})
+	strictShell := config.StrictShell
+	t.Cleanup(func() { config.StrictShell = strictShell })
+	for _, strict := range []bool{false, true} {
+		config.StrictShell = strict
+		src, want := "A := $(echo oops >&2; exit 3)\n", "Runfile:1: command substitution failed (exit code 3):\noops"
+		if !strict {
+			src, want = ".SHELL.STRICT = true\n"+src, "Runfile:2: command substitution failed (exit code 3):\noops"
+		}
+		rf := processRunfile(src)
+		err := tryProcess(func() { rf.Scope.GetVar("A") })
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("--strict=%v: err = %v, want %q", strict, err, want)
+		}
+	}
+}
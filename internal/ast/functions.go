@@ -0,0 +1,204 @@
+package ast
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/goreleaser/fileglob"
+	"github.com/tekwizely/run/internal/config"
+	"github.com/tekwizely/run/internal/runfile"
+)
+
+// function defines a builtin function, invoked via '$(fn:name args)'.
+//
+type function struct {
+	minArgs int
+	maxArgs int // -1 = unlimited
+	call    func(args []string) (string, error)
+}
+
+// functions maps function names to their definitions.
+//
+var functions = map[string]*function{
+	"upper":      {1, 1, func(args []string) (string, error) { return strings.ToUpper(args[0]), nil }},
+	"lower":      {1, 1, func(args []string) (string, error) { return strings.ToLower(args[0]), nil }},
+	"trim":       {1, 2, fnTrim},
+	"replace":    {3, 3, func(args []string) (string, error) { return strings.Replace(args[2], args[0], args[1], -1), nil }},
+	"join":       {1, -1, func(args []string) (string, error) { return strings.Join(args[1:], args[0]), nil }},
+	"basename":   {1, 2, fnBasename},
+	"dirname":    {1, 1, func(args []string) (string, error) { return filepath.Dir(args[0]), nil }},
+	"abspath":    {1, 1, func(args []string) (string, error) { return filepath.Abs(runfilePath(args[0])) }},
+	"exists":     {1, 1, fnExists},
+	"glob":       {1, -1, fnGlob},
+	"sha256file": {1, 1, fnSha256File},
+	"env":        {1, 2, fnEnv},
+	"now":        {0, 1, fnNow},
+	"uuid":       {0, 0, fnUUID},
+}
+
+// IsFnName returns true if the name is a builtin function.
+//
+func IsFnName(name string) bool {
+	_, ok := functions[name]
+	return ok
+}
+
+// CheckFn verifies the function exists and accepts the number of args given.
+//
+func CheckFn(name string, argCnt int) error {
+	fn, ok := functions[name]
+	if !ok {
+		return fmt.Errorf("unknown function: fn:%s", name)
+	}
+	if argCnt < fn.minArgs || (fn.maxArgs >= 0 && argCnt > fn.maxArgs) {
+		switch {
+		case fn.minArgs == fn.maxArgs:
+			return fmt.Errorf("fn:%s: expecting %d arg(s), got %d", name, fn.minArgs, argCnt)
+		case fn.maxArgs < 0:
+			return fmt.Errorf("fn:%s: expecting at least %d arg(s), got %d", name, fn.minArgs, argCnt)
+		default:
+			return fmt.Errorf("fn:%s: expecting %d to %d args, got %d", name, fn.minArgs, fn.maxArgs, argCnt)
+		}
+	}
+	return nil
+}
+
+// ScopeValueFn wraps a builtin function call.
+//
+type ScopeValueFn struct {
+	Runfile string
+	Line    int
+	Name    string
+	Args    []ScopeValueNode
+}
+
+// Apply applies the node to the scope, returning the value.
+//
+func (a *ScopeValueFn) Apply(s *runfile.Scope) string {
+	args := make([]string, len(a.Args))
+	for i, arg := range a.Args {
+		args[i] = arg.Apply(s)
+	}
+	result, err := functions[a.Name].call(args)
+	if err != nil {
+		panic(fmt.Errorf("%s:%d: fn:%s: %s", a.Runfile, a.Line, a.Name, err))
+	}
+	return result
+}
+
+// runfilePath resolves a relative path against the primary Runfile's directory (.RUNFILE.DIR), as with INCLUDE.
+//
+func runfilePath(path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+	return filepath.Join(config.RunfileAbsDir, path)
+}
+
+// fnTrim trims whitespace, or the optional cutset, from both ends of the value.
+//
+func fnTrim(args []string) (string, error) {
+	if len(args) > 1 {
+		return strings.Trim(args[0], args[1]), nil
+	}
+	return strings.TrimSpace(args[0]), nil
+}
+
+// fnBasename returns the last element of the path, removing the optional suffix.
+//
+func fnBasename(args []string) (string, error) {
+	base := filepath.Base(args[0])
+	if len(args) > 1 && base != args[1] {
+		base = strings.TrimSuffix(base, args[1])
+	}
+	return base, nil
+}
+
+// fnExists returns "true" if the path exists, else "".
+//
+func fnExists(args []string) (string, error) {
+	if _, err := os.Stat(runfilePath(args[0])); err == nil {
+		return "true", nil
+	} else if !os.IsNotExist(err) {
+		return "", err
+	}
+	return "", nil
+}
+
+// fnGlob returns the (space-separated) files matching the patterns.
+// Matches for relative patterns are relative to the primary Runfile's directory, as is the pattern.
+//
+func fnGlob(args []string) (string, error) {
+	var matches []string
+	for _, pattern := range args {
+		files, err := fileglob.Glob(runfilePath(pattern), fileglob.MaybeRootFS)
+		if err != nil {
+			return "", err
+		}
+		sort.Strings(files)
+		for _, file := range files {
+			if rel, err := filepath.Rel(config.RunfileAbsDir, file); err == nil && !filepath.IsAbs(pattern) {
+				file = rel
+			}
+			matches = append(matches, file)
+		}
+	}
+	return strings.Join(matches, " "), nil
+}
+
+// fnSha256File returns the hex-encoded sha256 digest of the file.
+//
+func fnSha256File(args []string) (string, error) {
+	file, err := os.Open(runfilePath(args[0]))
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = file.Close() }()
+	hash := sha256.New()
+	if _, err = io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// fnEnv returns the value of the environment variable, or the optional default if not set.
+//
+func fnEnv(args []string) (string, error) {
+	if value, ok := os.LookupEnv(args[0]); ok {
+		return value, nil
+	}
+	if len(args) > 1 {
+		return args[1], nil
+	}
+	return "", nil
+}
+
+// fnNow returns the current time, formatted using the optional Go time layout (default RFC3339).
+//
+func fnNow(args []string) (string, error) {
+	layout := time.RFC3339
+	if len(args) > 0 {
+		layout = args[0]
+	}
+	return time.Now().Format(layout), nil
+}
+
+// fnUUID returns a random (version 4) UUID.
+//
+func fnUUID(_ []string) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // Version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // Variant 10
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
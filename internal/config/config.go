@@ -19,6 +19,10 @@ const (
 	// FlagPrivate marks a command as Private
 	//
 	FlagPrivate
+
+	// FlagOverride marks a command as intentionally overriding a previously registered command
+	//
+	FlagOverride
 )
 
 // Hidden returns true if flag represents Hidden
@@ -33,16 +37,46 @@ func (c CmdFlags) Private() bool {
 	return c&FlagPrivate > 0
 }
 
+// Override returns true if flag represents Override
+//
+func (c CmdFlags) Override() bool {
+	return c&FlagOverride > 0
+}
+
+// CmdDefinition captures where a runfile command is defined.
+//
+type CmdDefinition struct {
+	Runfile string
+	Line    int
+	Shell   string
+}
+
 // Command is an abstraction for a command, allowing us to mix runfile commands and custom comments (help, list, etc).
 //
 type Command struct {
-	Flags   CmdFlags
-	Name    string
-	Title   string
-	Help    func()
-	Run     func([]string, map[string]string, io.Writer) int
-	Rename  func(string) // Rename Command to script Name in 'main' mode
-	Builtin bool
+	Flags       CmdFlags
+	Name        string
+	Aliases     []string // Registered in CommandMap along with Name
+	Group       string   // Used to group commands when listing them
+	Title       string
+	Describe    func() (title string, group string) // Evaluates Title and Group on first use, i.e. when listing; nil once evaluated
+	Help        func()
+	Run         func([]string, map[string]string, io.Writer) int
+	Rename      func(string) // Rename Command to script Name in 'main' mode
+	Builtin     bool
+	Yields      bool             // Builtin gives way to a runfile command or alias of the same name, as runfiles may already use the name
+	Definitions []*CmdDefinition // Runfile definitions, in override order; Last definition is active
+	DescFrom    *CmdDefinition   // Definition providing the title/description; nil if none
+}
+
+// LoadDescription evaluates the command's Title and Group, if deferred via Describe.
+//
+func (c *Command) LoadDescription() {
+	if c.Describe != nil {
+		describe := c.Describe
+		c.Describe = nil
+		c.Title, c.Group = describe()
+	}
 }
 
 // DefaultShell specifies which shell to use for command scripts and sub-shells if none explicitly defined.
@@ -113,6 +147,11 @@ var CurrentRunfileAbs string
 //
 var CurrentRunfileAbsDir string
 
+// CurrentNamespace is the namespace of the runfile currently being processed.
+// Set via INCLUDE ... AS <namespace>; Nested namespaces are joined with ':'.
+//
+var CurrentNamespace string
+
 // IncludeCycleMap tracks included Runfiles to avoid infinite loops. Key = abs file paths of included Runfile
 //
 var IncludeCycleMap = map[string]struct{}{}
@@ -142,6 +181,54 @@ var ShowCmdShells = false
 //
 var ShowNotices = false
 
+// PrefixMatch enables resolving commands by unique prefix.
+// Enabled via .RUN.PREFIX_MATCH attribute or $RUN_PREFIX_MATCH env var.
+//
+var PrefixMatch = false
+
+// StrictShell aborts if a command substitution exits with a non-zero exit code.
+// Enabled via --strict flag, or per-runfile via the .SHELL.STRICT attribute.
+//
+var StrictShell = false
+
+// StrictVars treats references to undefined variables as errors.
+// Enabled via --strict-vars flag, or per-runfile via the .STRICT_VARS attribute.
+//
+var StrictVars = false
+
+// CheckMode records references to undefined variables, instead of failing on them.
+// Shell substitutions are not executed in check mode.
+// Enabled via the 'check' builtin.
+//
+var CheckMode = false
+
+// Profile is the active profile, used to select INCLUDE.ENV files.
+// Set via --profile <name> or $RUN_PROFILE env var; Exposed as the .PROFILE attribute.
+//
+var Profile string
+
+// VarOverrides holds variable values specified on the command line.
+// Set via --set NAME=value, --set-file <file> or leading NAME=value args.
+// Overrides take priority over any assignments within the Runfile.
+//
+var VarOverrides = map[string]string{}
+
+// WorkspaceMode runs the command within each workspace Runfile, instead of the primary Runfile.
+// Enabled via -w | --workspace flag.
+//
+var WorkspaceMode = false
+
+// WorkspaceParallel runs the workspace Runfiles in parallel.
+// Enabled via --workspace-parallel flag.
+//
+var WorkspaceParallel = false
+
+// WorkspaceProject indicates run was invoked by workspace mode, to run the command within a single project Runfile.
+// If the command is not defined, run exits quietly, reporting the status via the file named by $RUN_WORKSPACE_STATUS.
+// Enabled via (internal) --workspace-project flag.
+//
+var WorkspaceProject = false
+
 // EnableRunfileOverride indicates if $RUNFILE env var or '-r | --runfile' arguments are supported in the current mode.
 //
 var EnableRunfileOverride = true
@@ -7,13 +7,17 @@ import (
 	"log"
 	"os"
 	"os/exec"
+	"sync"
 
 	"github.com/tekwizely/run/internal/config"
 )
 
-var tmpDir string
+var (
+	tmpDir      string
+	tmpDirMutex sync.Mutex // Guards tmpDir creation, as scripts may be executed in parallel
+)
 
-func executeScript(shell string, script []string, args []string, env map[string]string, prefix string, out io.Writer) int {
+func executeScript(shell string, script []string, args []string, env map[string]string, prefix string, in io.Reader, out io.Writer, errOut io.Writer) int {
 	if shell == "" {
 		panic(config.ErrShell)
 	}
@@ -68,9 +72,9 @@ func executeScript(shell string, script []string, args []string, env map[string]
 		cmd = exec.Command("/usr/bin/env", append([]string{shell, tmpFile.Name()}, args...)...)
 	}
 
-	cmd.Stdin = os.Stdin
+	cmd.Stdin = in
 	cmd.Stdout = out
-	cmd.Stderr = os.Stderr
+	cmd.Stderr = errOut
 	cmd.Env = os.Environ()
 	// Merge passed-in env with os environment
 	//
@@ -88,27 +92,38 @@ func executeScript(shell string, script []string, args []string, env map[string]
 }
 
 // ExecuteCmdScript executes a command script.
+// errOut receives the stderr of the script, allowing callers to buffer it.
+//
+func ExecuteCmdScript(shell string, script []string, args []string, env map[string]string, out io.Writer, errOut io.Writer) int {
+	return executeScript(shell, script, args, env, "cmd", os.Stdin, out, errOut)
+}
+
+// ExecuteCmdScriptNoStdin executes a command script without stdin.
+// Used when executing scripts in parallel, as they cannot share stdin.
 //
-func ExecuteCmdScript(shell string, script []string, args []string, env map[string]string, out io.Writer) int {
-	return executeScript(shell, script, args, env, "cmd", out)
+func ExecuteCmdScriptNoStdin(shell string, script []string, args []string, env map[string]string, out io.Writer, errOut io.Writer) int {
+	return executeScript(shell, script, args, env, "cmd", nil, out, errOut)
 }
 
 // ExecuteSubCommand executes a command substitution.
+// errOut receives the stderr of the command, allowing callers to capture it.
 //
-func ExecuteSubCommand(shell string, command string, env map[string]string, out io.Writer) int {
-	return executeScript(shell, []string{command}, []string{}, env, "sub", out)
+func ExecuteSubCommand(shell string, command string, env map[string]string, out io.Writer, errOut io.Writer) int {
+	return executeScript(shell, []string{command}, []string{}, env, "sub", os.Stdin, out, errOut)
 }
 
 // ExecuteTest will execute the test command against the supplied test string
 //
 func ExecuteTest(shell string, test string, env map[string]string) int {
-	return executeScript(shell, []string{test}, []string{}, env, "test", os.Stdout)
+	return executeScript(shell, []string{test}, []string{}, env, "test", os.Stdin, os.Stdout, os.Stderr)
 }
 
 // tmpFile creates a temporary file relative to tmpDir
 // Created files will be cleaned up in CleanupTemporaryDir
 //
 func tmpFile(pattern string) (*os.File, error) {
+	tmpDirMutex.Lock()
+	defer tmpDirMutex.Unlock()
 	if tmpDir == "" {
 		var err error
 		tmpDir, err = ioutil.TempDir("", "runfile-")
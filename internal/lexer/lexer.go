@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"container/list"
 	"strings"
+	"unicode"
 
 	"github.com/tekwizely/go-parsing/lexer"
 	"github.com/tekwizely/go-parsing/lexer/token"
@@ -18,9 +19,10 @@ type LexFn func(*LexContext, *lexer.Lexer) LexFn
 // LexContext allows us to track additional states of the lexer
 //
 type LexContext struct {
-	Fn      LexFn
-	fnStack *list.List
-	Tokens  token.Nexter
+	Fn       LexFn
+	fnStack  *list.List
+	Tokens   token.Nexter
+	isFnName func(name string) bool // Is the name a builtin function? i.e. '$(fn:name ...)'
 }
 
 // lex delegates incoming lexer calls to the configured fn
@@ -50,12 +52,14 @@ func (ctx *LexContext) PushFn(fn LexFn) {
 }
 
 // Lex initiates the lexer against a byte array
+// isFnName identifies builtin functions, as '$(fn:name ...)' is otherwise a shell substitution.
 //
-func Lex(fileBytes []byte) *LexContext {
+func Lex(fileBytes []byte, isFnName func(name string) bool) *LexContext {
 	reader := newReaderIgnoreCR(bytes.NewReader(fileBytes))
 	ctx := &LexContext{
-		Fn:      LexMain,
-		fnStack: list.New(),
+		Fn:       LexMain,
+		fnStack:  list.New(),
+		isFnName: isFnName,
 	}
 	ctx.Tokens = lexer.LexRuneReader(reader, ctx.lex)
 	return ctx
@@ -66,6 +70,13 @@ func Lex(fileBytes []byte) *LexContext {
 func LexMain(_ *LexContext, l *lexer.Lexer) LexFn {
 
 	switch {
+	// ::=
+	//
+	case l.CanPeek(3) && l.Peek(1) == runeColon && l.Peek(2) == runeColon && l.Peek(3) == runeEquals:
+		l.Next() // :
+		l.Next() // :
+		l.Next() // =
+		l.EmitType(TokenDColonEquals)
 	// :=
 	//
 	case l.CanPeek(2) && l.Peek(1) == runeColon && l.Peek(2) == runeEquals:
@@ -78,6 +89,18 @@ func LexMain(_ *LexContext, l *lexer.Lexer) LexFn {
 		l.Next() // ?
 		l.Next() // =
 		l.EmitType(TokenQMarkEquals)
+	// +=
+	//
+	case l.CanPeek(2) && l.Peek(1) == runePlus && l.Peek(2) == runeEquals:
+		l.Next() // +
+		l.Next() // =
+		l.EmitType(TokenPlusEquals)
+	// !=
+	//
+	case l.CanPeek(2) && l.Peek(1) == runeBang && l.Peek(2) == runeEquals:
+		l.Next() // !
+		l.Next() // =
+		l.EmitType(TokenBangEquals)
 	// Single-Char Token - Check AFTER multi-char tokens
 	//
 	case bytes.ContainsRune(singleRunes, l.Peek(1)):
@@ -118,6 +141,10 @@ func LexMain(_ *LexContext, l *lexer.Lexer) LexFn {
 	//
 	case matchNewline(l):
 		l.EmitType(TokenNewline)
+	// Command Path - [.!]? DashID ( / DashID )+
+	//
+	case matchCommandPathID(l):
+		l.EmitToken(TokenCommandDefID)
 	// DotID - Starts with .
 	//
 	case matchDotID(l):
@@ -127,7 +154,7 @@ func LexMain(_ *LexContext, l *lexer.Lexer) LexFn {
 	case matchAnyID(l):
 		name := strings.ToUpper(l.PeekToken())
 		switch {
-		case isMainToken(name):
+		case isMainToken(name) && !isSoftMainTokenName(name, l):
 			l.EmitType(mainTokens[name])
 		// Only main tokens can contain '.'
 		//
@@ -143,7 +170,7 @@ func LexMain(_ *LexContext, l *lexer.Lexer) LexFn {
 	case matchCommandDefID(l):
 		name := strings.ToUpper(l.PeekToken())
 		switch {
-		case isMainToken(name):
+		case isMainToken(name) && !isSoftMainTokenName(name, l):
 			l.EmitType(mainTokens[name])
 		case strings.HasPrefix(name, "."): // Can only match at front
 			l.EmitToken(TokenCommandDefID)
@@ -165,7 +192,99 @@ func LexMain(_ *LexContext, l *lexer.Lexer) LexFn {
 	return LexMain
 }
 
+// isSoftCmdConfigDesc returns true if the (as written) token is a soft doc block keyword being used as description text,
+// i.e. it is not upper-case, or the rest of the line does not match the attribute.
+//
+func isSoftCmdConfigDesc(name string, l *lexer.Lexer) bool {
+	upper := strings.ToUpper(name)
+	if _, ok := softCmdConfigTokens[upper]; !ok {
+		return false
+	}
+	if name != upper {
+		return true
+	}
+	i := 1
+	skipSpace := func() {
+		for l.CanPeek(i) && isSpaceOrTab(l.Peek(i)) {
+			i++
+		}
+	}
+	atEOL := func() bool {
+		return !l.CanPeek(i) || l.Peek(i) == '\r' || l.Peek(i) == '\n'
+	}
+	switch upper {
+	// Flags - Nothing else on the line
+	//
+	case "DEFAULT", "FOREACH.PARALLEL":
+		skipSpace()
+		return !atEOL()
+	// NAME in ...
+	//
+	case "FOREACH":
+		skipSpace()
+		if !l.CanPeek(i) || !isAlphaUnder(l.Peek(i)) {
+			return true
+		}
+		for l.CanPeek(i) && isAlphaNumUnder(l.Peek(i)) {
+			i++
+		}
+		skipSpace()
+		if !l.CanPeek(i+1) || (l.Peek(i) != 'i' && l.Peek(i) != 'I') || (l.Peek(i+1) != 'n' && l.Peek(i+1) != 'N') {
+			return true
+		}
+		i += 2
+		return !atEOL() && !unicode.IsSpace(l.Peek(i))
+	}
+	return false
+}
+
+// isSoftMainTokenName returns true if the (upper-cased) token is a soft keyword being used as a name,
+// i.e. the rest of the line continues as a command definition or assignment.
+//
+func isSoftMainTokenName(name string, l *lexer.Lexer) bool {
+	if _, ok := softMainTokens[name]; !ok {
+		return false
+	}
+	i := 1
+	skipSpace := func() {
+		for l.CanPeek(i) && isSpaceOrTab(l.Peek(i)) {
+			i++
+		}
+	}
+	skipSpace()
+	if !l.CanPeek(i) {
+		return false
+	}
+	switch l.Peek(i) {
+	// Command definition ( NAME: ), or assignment ( NAME := | NAME ::= )
+	//
+	case runeColon, runeEquals:
+		return true
+	// Assignment ( NAME ?= | NAME += | NAME != )
+	//
+	case runeQMark, runePlus, runeBang:
+		return l.CanPeek(i+1) && l.Peek(i+1) == runeEquals
+	// Command definition with shell ( NAME (shell): )
+	//
+	case runeLParen:
+		i++
+		skipSpace()
+		for l.CanPeek(i) && isAlphaNumUnder(l.Peek(i)) {
+			i++
+		}
+		skipSpace()
+		if !l.CanPeek(i) || l.Peek(i) != runeRParen {
+			return false
+		}
+		i++
+		skipSpace()
+		return l.CanPeek(i) && l.Peek(i) == runeColon
+	}
+	return false
+}
+
 // LexAssignmentValue delegates to other rValue lexers
+// A list literal is a '[' followed by whitespace, i.e. '[ a b ]'.
 //
 func LexAssignmentValue(_ *LexContext, l *lexer.Lexer) LexFn {
 	ignoreSpace(l)
@@ -178,10 +297,79 @@ func LexAssignmentValue(_ *LexContext, l *lexer.Lexer) LexFn {
 		return LexDQString
 	case runeDollar:
 		return lexDollarString
+	case runeLBracket:
+		// List literals require whitespace after the '[', so values like '[abc]' remain strings
+		//
+		if l.CanPeek(2) && isSpaceOrTab(l.Peek(2)) {
+			l.Next()
+			l.EmitType(TokenLBracket)
+			return lexListItems
+		}
 	}
 	return lexUQString
 }
 
+// lexListItems lexes whitespace-separated list items, up to the closing bracket.
+// Items may contain quoted strings, variable references and substitutions.
+//
+func lexListItems(ctx *LexContext, l *lexer.Lexer) LexFn {
+	switch {
+	// Item separator
+	//
+	case matchOneOrMore(l, isSpaceOrTab):
+		l.EmitType(TokenListItemSep)
+	// Consume a run of printable, non-space non-special characters
+	//
+	case matchOneOrMore(l, isListItemRune):
+		l.EmitToken(TokenRunes)
+	// Back-slash '\'
+	//
+	case matchRune(l, runeBackSlash):
+		// In list items, '\', '$', '"', "'", ' ' and ']' are escapable
+		// Anything else is considered two separate characters
+		//
+		if matchRune(l, runeBackSlash, runeDollar, runeDQuote, runeSQuote, runeSpace, runeRBracket) {
+			l.EmitToken(TokenEscapeSequence)
+		} else {
+			l.EmitToken(TokenRunes)
+		}
+	// Quoted strings
+	//
+	case l.CanPeek(1) && l.Peek(1) == runeDQuote:
+		ctx.PushFn(lexListItems)
+		l.EmitType(TokenDQStringStart)
+		return LexDQString
+	case l.CanPeek(1) && l.Peek(1) == runeSQuote:
+		ctx.PushFn(lexListItems)
+		l.EmitType(TokenSQStringStart)
+		return LexSQString
+	// Variable reference / Substitution
+	//
+	case l.CanPeek(1) && l.Peek(1) == runeDollar:
+		if l.CanPeek(2) {
+			switch l.Peek(2) {
+			case runeLBrace:
+				ctx.PushFn(lexListItems)
+				l.EmitType(TokenVarRefStart)
+				return LexVarRef
+			case runeLParen:
+				ctx.PushFn(lexListItems)
+				l.EmitType(TokenSubCmdStart)
+				return LexSubCmd
+			}
+		}
+		l.Next() // Consume $
+		l.EmitToken(TokenRunes)
+	// Better be Close Bracket ']'
+	//
+	default:
+		expectRune(l, runeRBracket, "expecting r-bracket (']')")
+		l.EmitType(TokenRBracket)
+		return nil
+	}
+	return lexListItems
+}
+
 // lexDollarString
 //
 func lexDollarString(_ *LexContext, l *lexer.Lexer) LexFn {
@@ -204,7 +392,7 @@ func lexDollarString(_ *LexContext, l *lexer.Lexer) LexFn {
 	return nil
 }
 
-// LexVarRef matches: [ '$' '{' [A-Za-z0-9_.]* '}' ]
+// LexVarRef matches: [ '$' '{' '#'? [A-Za-z0-9_.]* ( '[' ( '@' | [0-9]+ ) ']' )? ( operator word )? '}' ]
 //
 func LexVarRef(_ *LexContext, l *lexer.Lexer) LexFn {
 	// Dollar
@@ -215,21 +403,199 @@ func LexVarRef(_ *LexContext, l *lexer.Lexer) LexFn {
 	//
 	expectRune(l, runeLBrace, "expecting l-brace ('{')")
 	l.EmitType(TokenLBrace)
+	// Length '#'
+	//
+	if matchRune(l, runeHash) {
+		l.EmitType(TokenVarLength)
+	}
 	// Variable Name
 	//
 	matchZeroOrMore(l, isAlphaNumUnderDot)
 	l.EmitToken(TokenRunes) // Could be empty
+	// List Index
+	//
+	if matchRune(l, runeLBracket) {
+		l.Clear() // Discard '['
+		// Index is validated by the parser, for better error messages
+		//
+		matchZeroOrMore(l, isListIndexRune)
+		l.EmitToken(TokenVarIndex)
+		if matchRune(l, runeRBracket) {
+			l.EmitType(TokenRBracket)
+		}
+	}
+	// Operator
+	//
+	switch {
+	// ':-' | ':=' | ':?' | ':+' | ':' (substring)
+	//
+	case matchRune(l, runeColon):
+		matchRune(l, runeDash, runeEquals, runeQMark, runePlus)
+	// '-' | '=' | '?' | '+'
+	//
+	case matchRune(l, runeDash, runeEquals, runeQMark, runePlus):
+	// '#' | '##' | '%' | '%%'
+	//
+	case matchRune(l, runeHash):
+		matchRune(l, runeHash)
+	case matchRune(l, runePercent):
+		matchRune(l, runePercent)
+	// '/' | '//'
+	//
+	case matchRune(l, runeSlash):
+		matchRune(l, runeSlash)
+		l.EmitToken(TokenVarOp)
+		return lexVarRefPattern
 	// Close Brace
 	//
-	expectRune(l, runeRBrace, "expecting r-brace ('}')")
-	l.EmitType(TokenRBrace)
+	default:
+		expectRune(l, runeRBrace, "expecting r-brace ('}')")
+		l.EmitType(TokenRBrace)
+		return nil
+	}
+	l.EmitToken(TokenVarOp)
+	return lexVarRefWord
+}
 
-	return nil
+// lexVarRefWord lexes the word following a parameter expansion operator, up to the closing brace.
+//
+func lexVarRefWord(ctx *LexContext, l *lexer.Lexer) LexFn {
+	return lexVarRefOperand(ctx, l, lexVarRefWord, false)
+}
+
+// lexVarRefPattern lexes the pattern of a replacement expansion, up to the '/' separator or the closing brace.
+//
+func lexVarRefPattern(ctx *LexContext, l *lexer.Lexer) LexFn {
+	return lexVarRefOperand(ctx, l, lexVarRefPattern, true)
+}
+
+// lexVarRefOperand lexes a parameter expansion operand, which may contain nested variable references.
+//
+func lexVarRefOperand(ctx *LexContext, l *lexer.Lexer, self LexFn, pattern bool) LexFn {
+	isRunes := isPrintNonRBraceNonBackslashNonDollar
+	if pattern {
+		isRunes = isPrintNonRBraceNonBackslashNonDollarNonSlash
+	}
+	switch {
+	// Consume a run of printable, non-brace non-escape characters
+	//
+	case matchOneOrMore(l, isRunes):
+		l.EmitToken(TokenRunes)
+	// Back-slash '\'
+	//
+	case matchRune(l, runeBackSlash):
+		// Currently only '\', '$', '}' and '/' are escapable
+		// Anything else is considered two separate characters
+		//
+		if matchRune(l, runeBackSlash, runeDollar, runeRBrace, runeSlash) {
+			l.EmitToken(TokenEscapeSequence)
+		} else {
+			l.EmitToken(TokenRunes)
+		}
+	// Variable reference
+	//
+	case l.CanPeek(1) && l.Peek(1) == runeDollar:
+		if l.CanPeek(2) && l.Peek(2) == runeLBrace {
+			ctx.PushFn(self)
+			l.EmitType(TokenVarRefStart)
+			return LexVarRef
+		}
+		l.Next() // Consume $
+		l.EmitToken(TokenRunes)
+	// Pattern / Replacement separator
+	//
+	case pattern && matchRune(l, runeSlash):
+		l.EmitType(TokenVarOpSep)
+		return lexVarRefWord
+	// Close Brace
+	//
+	default:
+		expectRune(l, runeRBrace, "expecting r-brace ('}')")
+		l.EmitType(TokenRBrace)
+		return nil
+	}
+	return self
 }
 
-// LexSubCmd matches: [ '$' '(' [::print::] ')' ]
+// lexFnArgs lexes whitespace-separated function args, up to the closing paren.
+// Args may contain quoted strings, variable references and nested substitutions.
 //
-func LexSubCmd(_ *LexContext, l *lexer.Lexer) LexFn {
+func lexFnArgs(ctx *LexContext, l *lexer.Lexer) LexFn {
+	switch {
+	// Arg separator
+	//
+	case matchOneOrMore(l, isSpaceOrTab):
+		l.EmitType(TokenFnArgSep)
+	// Consume a run of printable, non-space non-special characters
+	//
+	case matchOneOrMore(l, isFnArgRune):
+		l.EmitToken(TokenRunes)
+	// Back-slash '\'
+	//
+	case matchRune(l, runeBackSlash):
+		// In function args, '\', '$', '"', "'", ' ' and ')' are escapable
+		// Anything else is considered two separate characters
+		//
+		if matchRune(l, runeBackSlash, runeDollar, runeDQuote, runeSQuote, runeSpace, runeRParen) {
+			l.EmitToken(TokenEscapeSequence)
+		} else {
+			l.EmitToken(TokenRunes)
+		}
+	// Quoted strings
+	//
+	case l.CanPeek(1) && l.Peek(1) == runeDQuote:
+		ctx.PushFn(lexFnArgs)
+		l.EmitType(TokenDQStringStart)
+		return LexDQString
+	case l.CanPeek(1) && l.Peek(1) == runeSQuote:
+		ctx.PushFn(lexFnArgs)
+		l.EmitType(TokenSQStringStart)
+		return LexSQString
+	// Variable reference / Nested substitution
+	//
+	case l.CanPeek(1) && l.Peek(1) == runeDollar:
+		if l.CanPeek(2) {
+			switch l.Peek(2) {
+			case runeLBrace:
+				ctx.PushFn(lexFnArgs)
+				l.EmitType(TokenVarRefStart)
+				return LexVarRef
+			case runeLParen:
+				ctx.PushFn(lexFnArgs)
+				l.EmitType(TokenSubCmdStart)
+				return LexSubCmd
+			}
+		}
+		l.Next() // Consume $
+		l.EmitToken(TokenRunes)
+	// Better be Close Paren ')'
+	//
+	default:
+		expectRune(l, runeRParen, "expecting r-paren (')')")
+		l.EmitType(TokenRParen)
+		return nil
+	}
+	return lexFnArgs
+}
+
+// isFnCall peeks past 'fn:' to check if it is followed by the name of a builtin function.
+//
+func isFnCall(ctx *LexContext, l *lexer.Lexer) bool {
+	if !l.CanPeek(3) || l.Peek(1) != 'f' || l.Peek(2) != 'n' || l.Peek(3) != runeColon {
+		return false
+	}
+	var name []rune
+	for i := 4; l.CanPeek(i) && isAlphaNumUnderDash(l.Peek(i)); i++ {
+		name = append(name, l.Peek(i))
+	}
+	return ctx.isFnName != nil && ctx.isFnName(string(name))
+}
+
+// LexSubCmd matches: [ '$' '(' [::print::] ')' ] | [ '$' '(' 'fn:' name args ')' ]
+// 'fn:' is only treated as a function call if followed by the name of a builtin function,
+// so existing shell substitutions starting with 'fn:' are unaffected.
+//
+func LexSubCmd(ctx *LexContext, l *lexer.Lexer) LexFn {
 	// Dollar
 	//
 	expectRune(l, runeDollar, "expecting dollar ('$')")
@@ -238,6 +604,20 @@ func LexSubCmd(_ *LexContext, l *lexer.Lexer) LexFn {
 	//
 	expectRune(l, runeLParen, "expecting l-paren ('(')")
 	l.EmitType(TokenLParen)
+	// Function call?
+	//
+	if isFnCall(ctx, l) {
+		l.Next()
+		l.Next()
+		l.Next()
+		l.Clear() // Discard 'fn:'
+		if !matchOneOrMore(l, isAlphaNumUnderDash) {
+			l.EmitError("expecting function name")
+			return nil
+		}
+		l.EmitToken(TokenFnName)
+		return lexFnArgs
+	}
 	// Keep going until we find close paren
 	//
 	for l.CanPeek(1) {
@@ -276,6 +656,118 @@ func LexAssert(ctx *LexContext, l *lexer.Lexer) LexFn {
 	return lexTestString
 }
 
+// LexIf assumes 'IF' has already been matched.
+// The condition is either a test string (same as ASSERT), or the left side of a comparison.
+//
+func LexIf(_ *LexContext, l *lexer.Lexer) LexFn {
+	ignoreSpace(l)
+	if peekRuneEquals(l, runeLBracket) || peekRuneEquals(l, runeLParen) {
+		return lexTestString
+	}
+	return LexIfValue
+}
+
+// LexIfValue lexes a value of an IF comparison.
+// Unquoted values may combine characters, quoted strings, variable references and substitutions, i.e. ${MODE}-x
+// The value ends at the first whitespace or comparison operator outside of quotes.
+//
+func LexIfValue(_ *LexContext, l *lexer.Lexer) LexFn {
+	ignoreSpace(l)
+	return lexIfValueParts
+}
+
+// lexIfValueParts
+//
+func lexIfValueParts(ctx *LexContext, l *lexer.Lexer) LexFn {
+	switch {
+	// Consume a run of printable, non-space non-special characters
+	//
+	case matchIfValueRunes(l):
+		l.EmitToken(TokenRunes)
+	// Quoted strings
+	//
+	case peekRuneEquals(l, runeDQuote):
+		ctx.PushFn(lexIfValueParts)
+		l.EmitType(TokenDQStringStart)
+		return LexDQString
+	case peekRuneEquals(l, runeSQuote):
+		ctx.PushFn(lexIfValueParts)
+		l.EmitType(TokenSQStringStart)
+		return LexSQString
+	// Variable reference / Substitution
+	//
+	case peekRuneEquals(l, runeDollar):
+		if l.CanPeek(2) {
+			switch l.Peek(2) {
+			case runeLBrace:
+				ctx.PushFn(lexIfValueParts)
+				l.EmitType(TokenVarRefStart)
+				return LexVarRef
+			case runeLParen:
+				ctx.PushFn(lexIfValueParts)
+				l.EmitType(TokenSubCmdStart)
+				return LexSubCmd
+			}
+		}
+		l.Next() // Consume $
+		l.EmitToken(TokenRunes)
+	// Whitespace, comparison operator, newline or EOF
+	//
+	default:
+		l.EmitType(TokenIfValueEnd)
+		return nil
+	}
+	return lexIfValueParts
+}
+
+// matchIfValueRunes matches a run of unquoted IF value characters, stopping before any comparison operator.
+//
+func matchIfValueRunes(l *lexer.Lexer) bool {
+	matched := false
+	for l.CanPeek(1) && isIfValueRune(l.Peek(1)) && !peekIfCompareOp(l) {
+		l.Next()
+		matched = true
+	}
+	return matched
+}
+
+// peekIfCompareOp returns true if the next runes are a comparison operator: '==' | '!='
+//
+func peekIfCompareOp(l *lexer.Lexer) bool {
+	return l.CanPeek(2) && (l.Peek(1) == runeEquals || l.Peek(1) == runeBang) && l.Peek(2) == runeEquals
+}
+
+// LexIfCompareOp lexes the operator of an IF comparison: '==' | '!='
+// Anything else is emitted as TokenRunes, for the parser to report.
+//
+func LexIfCompareOp(_ *LexContext, l *lexer.Lexer) LexFn {
+	ignoreSpace(l)
+	switch {
+	case peekIfCompareOp(l) && l.Peek(1) == runeEquals:
+		l.Next()
+		l.Next()
+		l.EmitType(TokenEqualsEquals)
+	case peekIfCompareOp(l):
+		l.Next()
+		l.Next()
+		l.EmitType(TokenBangEquals)
+	default:
+		matchZeroOrMore(l, isPrintNonSpace)
+		l.EmitToken(TokenRunes)
+	}
+	return nil
+}
+
+// LexIfEnd lexes any unexpected text following an IF comparison, as TokenRunes, for the parser to report.
+//
+func LexIfEnd(_ *LexContext, l *lexer.Lexer) LexFn {
+	ignoreSpace(l)
+	if matchOneOrMore(l, isNonReturn) {
+		l.EmitToken(TokenRunes)
+	}
+	return nil
+}
+
 // LexAssertMessage parses an (optional) assertion error message
 //
 func LexAssertMessage(_ *LexContext, l *lexer.Lexer) LexFn {
@@ -509,6 +1001,56 @@ func lexDQStringElement(ctx *LexContext, l *lexer.Lexer) LexFn {
 	return lexDQStringElement
 }
 
+// LexShellAssignmentValue lexes the command of a shell assignment ('!='), up to the end of the line.
+// Only variable references and function calls are interpolated, everything else is passed to the shell as-is.
+//
+func LexShellAssignmentValue(_ *LexContext, l *lexer.Lexer) LexFn {
+	ignoreSpace(l)
+	return lexShellAssignmentElement
+}
+
+// lexShellAssignmentElement
+//
+func lexShellAssignmentElement(ctx *LexContext, l *lexer.Lexer) LexFn {
+	switch {
+	// Consume a run of printable, non-escape characters
+	//
+	case matchOneOrMore(l, isPrintNonBackslashNonDollarNonReturn):
+		l.EmitToken(TokenRunes)
+	// Back-slash '\'
+	//
+	case matchRune(l, runeBackSlash):
+		// Currently only '\' and '$' are escapable
+		// Anything else is considered two separate characters
+		//
+		if matchRune(l, runeBackSlash, runeDollar) {
+			l.EmitToken(TokenEscapeSequence)
+		} else {
+			l.EmitToken(TokenRunes)
+		}
+	// Variable reference / Function call
+	//
+	case l.CanPeek(1) && l.Peek(1) == runeDollar:
+		if l.CanPeek(2) && l.Peek(2) == runeLBrace {
+			ctx.PushFn(lexShellAssignmentElement)
+			l.EmitType(TokenVarRefStart)
+			return LexVarRef
+		}
+		if l.CanPeek(5) && l.Peek(2) == runeLParen && l.Peek(3) == 'f' && l.Peek(4) == 'n' && l.Peek(5) == runeColon {
+			ctx.PushFn(lexShellAssignmentElement)
+			l.EmitType(TokenSubCmdStart)
+			return LexSubCmd
+		}
+		l.Next() // Consume $
+		l.EmitToken(TokenRunes)
+	// End of line
+	//
+	default:
+		return nil
+	}
+	return lexShellAssignmentElement
+}
+
 // lexUQString lexes an Unquoted string (no quotes, no interpolation)
 //
 func lexUQString(_ *LexContext, l *lexer.Lexer) LexFn {
@@ -539,7 +1081,7 @@ func LexDocBlockDesc(ctx *LexContext, l *lexer.Lexer) LexFn {
 			//
 			if matchConfigAttrID(l) {
 				id := strings.ToUpper(l.PeekToken())
-				if t, ok := cmdConfigTokens[id]; ok {
+				if t, ok := cmdConfigTokens[id]; ok && !isSoftCmdConfigDesc(l.PeekToken(), l) {
 					// We've gone this far, let's go ahead and emit
 					// the attribute (vs rewind and re-scan)
 					//
@@ -582,7 +1124,7 @@ func LexDocBlockNQString(ctx *LexContext, l *lexer.Lexer) LexFn {
 		} else {
 			l.EmitToken(TokenRunes)
 		}
-	// Variable reference
+	// Variable reference / Function call
 	//
 	case l.CanPeek(1) && l.Peek(1) == runeDollar:
 		if l.CanPeek(2) && l.Peek(2) == runeLBrace {
@@ -590,6 +1132,11 @@ func LexDocBlockNQString(ctx *LexContext, l *lexer.Lexer) LexFn {
 			l.EmitType(TokenVarRefStart)
 			return LexVarRef
 		}
+		if l.CanPeek(5) && l.Peek(2) == runeLParen && l.Peek(3) == 'f' && l.Peek(4) == 'n' && l.Peek(5) == runeColon {
+			ctx.PushFn(LexDocBlockNQString)
+			l.EmitType(TokenSubCmdStart)
+			return LexSubCmd
+		}
 		l.Next() // Consume $
 		l.EmitToken(TokenRunes)
 	default:
@@ -657,6 +1204,13 @@ func LexCmdConfigUsage(_ *LexContext, l *lexer.Lexer) LexFn {
 	return LexDocBlockNQString
 }
 
+// LexCmdConfigGroup lexes a doc block GROUP line
+//
+func LexCmdConfigGroup(_ *LexContext, l *lexer.Lexer) LexFn {
+	ignoreSpace(l)
+	return LexDocBlockNQString
+}
+
 // LexCmdConfigOpt matches: name [ ! | ? | ?= VALUE ]
 //
 func LexCmdConfigOpt(_ *LexContext, l *lexer.Lexer) LexFn {
@@ -740,7 +1294,7 @@ func LexCmdConfigOptTail(_ *LexContext, l *lexer.Lexer) LexFn {
 		//
 		expectRune(l, runeDash, "expecting '-'")
 		l.Clear()
-		if matchOneOrMore(l, isAlphaNum) {
+		if matchOne(l, isAlphaNum) && matchZeroOrMore(l, isAlphaNumUnderDash) {
 			l.EmitToken(TokenConfigOptLong)
 		} else {
 			l.EmitError("expecting long flag name")
@@ -770,6 +1324,39 @@ func LexCmdConfigOptTail(_ *LexContext, l *lexer.Lexer) LexFn {
 	return LexDocBlockNQString
 }
 
+// LexCmdConfigAlias lexes a doc block ALIAS line: name [ ',' name ]*
+//
+func LexCmdConfigAlias(_ *LexContext, l *lexer.Lexer) LexFn {
+	lexDashIDList(l, "expecting alias name")
+	return nil
+}
+
+// LexCmdConfigOptions lexes a doc block OPTIONS line: name [ ',' name ]*
+//
+func LexCmdConfigOptions(_ *LexContext, l *lexer.Lexer) LexFn {
+	lexDashIDList(l, "expecting option set name")
+	return nil
+}
+
+// lexDashIDList lexes a comma-separated list of dash-ids: name [ ',' name ]*
+//
+func lexDashIDList(l *lexer.Lexer, errMsg string) {
+	ignoreSpace(l)
+	for {
+		if !matchDashID(l) {
+			l.EmitError(errMsg)
+			return
+		}
+		l.EmitToken(TokenDashID)
+		ignoreSpace(l)
+		if !matchRune(l, runeComma) {
+			return
+		}
+		l.EmitType(TokenComma)
+		ignoreSpace(l)
+	}
+}
+
 // LexCmdShellName lexes a command's shell
 //
 func LexCmdShellName(_ *LexContext, l *lexer.Lexer) LexFn {
@@ -783,6 +1370,34 @@ func LexCmdShellName(_ *LexContext, l *lexer.Lexer) LexFn {
 	return nil
 }
 
+// LexForeach lexes the start of a doc block FOREACH line: ID 'in'
+// The values are lexed separately.
+//
+func LexForeach(_ *LexContext, l *lexer.Lexer) LexFn {
+	ignoreSpace(l)
+	// Anything unexpected is emitted as TokenRunes, for the parser to report
+	//
+	if !matchID(l) {
+		matchZeroOrMore(l, isPrintNonSpace)
+		l.EmitToken(TokenRunes)
+		return nil
+	}
+	l.EmitToken(TokenID)
+	ignoreSpace(l)
+	if l.CanPeek(2) &&
+		(l.Peek(1) == 'i' || l.Peek(1) == 'I') &&
+		(l.Peek(2) == 'n' || l.Peek(2) == 'N') &&
+		(!l.CanPeek(3) || unicode.IsSpace(l.Peek(3))) {
+		l.Next() // i
+		l.Next() // n
+		l.EmitType(TokenIn)
+		return nil
+	}
+	matchZeroOrMore(l, isPrintNonSpace)
+	l.EmitToken(TokenRunes)
+	return nil
+}
+
 // LexExport lexes a global OR doc block EXPORT line
 //
 func LexExport(_ *LexContext, l *lexer.Lexer) LexFn {
@@ -803,9 +1418,14 @@ func LexExport(_ *LexContext, l *lexer.Lexer) LexFn {
 				case matchRune(l, runeEquals):
 					l.EmitType(TokenEquals)
 					return nil // Terminal, only 1 per line
-				// ':='
+				// ':=' | '::='
 				//
 				case matchRune(l, runeColon):
+					if matchRune(l, runeColon) {
+						expectRune(l, runeEquals, "expecting '='")
+						l.EmitType(TokenDColonEquals)
+						return nil // Terminal, only 1 per line
+					}
 					expectRune(l, runeEquals, "expecting '='")
 					l.EmitType(TokenEquals)
 					return nil // Terminal, only 1 per line
@@ -815,6 +1435,18 @@ func LexExport(_ *LexContext, l *lexer.Lexer) LexFn {
 					expectRune(l, runeEquals, "expecting '='")
 					l.EmitType(TokenQMarkEquals)
 					return nil // Terminal, only 1 per line
+				// '+='
+				//
+				case matchRune(l, runePlus):
+					expectRune(l, runeEquals, "expecting '='")
+					l.EmitType(TokenPlusEquals)
+					return nil // Terminal, only 1 per line
+				// '!='
+				//
+				case matchRune(l, runeBang):
+					expectRune(l, runeEquals, "expecting '='")
+					l.EmitType(TokenBangEquals)
+					return nil // Terminal, only 1 per line
 				}
 			}
 		// Attribute
@@ -851,12 +1483,78 @@ func LexExport(_ *LexContext, l *lexer.Lexer) LexFn {
 	return nil
 }
 
-// LexExpectCommandName matches a dash-id or throws an error
+// LexIncludeEnvProfiles lexes an optional profile list immediately following INCLUDE.ENV: '[' name [ ',' name ]* ']'
+// Followed by optional '!' | '?'
+//
+func LexIncludeEnvProfiles(ctx *LexContext, l *lexer.Lexer) LexFn {
+	if matchRune(l, runeLBracket) {
+		l.EmitType(TokenLBracket)
+		lexDashIDList(l, "expecting profile name")
+		ignoreSpace(l)
+		expectRune(l, runeRBracket, "expecting right-bracket (']')")
+		l.EmitType(TokenRBracket)
+	}
+	return LexMaybeBangOrQMark
+}
+
+// LexIncludeEnvModifier lexes an optional INCLUDE.ENV modifier: EXPORT | EXPAND
+// Emits TokenUnknownRune if no modifier present.
+//
+func LexIncludeEnvModifier(_ *LexContext, l *lexer.Lexer) LexFn {
+	ignoreSpace(l)
+	switch {
+	case matchKeyword(l, "EXPORT"):
+		l.EmitType(TokenExport)
+	case matchKeyword(l, "EXPAND"):
+		l.EmitType(TokenExpand)
+	default:
+		l.EmitType(TokenUnknownRune)
+	}
+	return nil
+}
+
+// LexIncludeVarsFrom lexes an optional INCLUDE.VARS key path: FROM <key.path>
+//
+func LexIncludeVarsFrom(_ *LexContext, l *lexer.Lexer) LexFn {
+	ignoreSpace(l)
+	if matchKeyword(l, "FROM") {
+		l.EmitType(TokenFrom)
+		ignoreSpace(l)
+		if !matchOneOrMore(l, isPrintNonSpace) {
+			l.EmitError("expecting key path")
+			return nil
+		}
+		l.EmitToken(TokenRunes)
+	}
+	return nil
+}
+
+// LexIncludeAs lexes an optional 'AS <namespace>' following an INCLUDE file pattern
+//
+func LexIncludeAs(_ *LexContext, l *lexer.Lexer) LexFn {
+	ignoreSpace(l)
+	if matchKeyword(l, "AS") {
+		l.EmitType(TokenAs)
+		ignoreSpace(l)
+		if !matchDashID(l) {
+			l.EmitError("expecting namespace")
+			return nil
+		}
+		l.EmitToken(TokenDashID)
+	}
+	return nil
+}
+
+// LexExpectCommandName matches a command reference or throws an error
+// '^' references the command overridden by the current command
 //
 func LexExpectCommandName(_ *LexContext, l *lexer.Lexer) LexFn {
 	ignoreSpace(l)
-	if !matchDashID(l) {
-		l.EmitError("expecting command name")
+	// Anything unexpected is emitted as TokenRunes, for the parser to report
+	//
+	if !matchRune(l, runeCaret) && !matchCommandRefID(l) {
+		matchZeroOrMore(l, isPrintNonSpace)
+		l.EmitToken(TokenRunes)
 		return nil
 	}
 	l.EmitToken(TokenDashID)
@@ -883,10 +1581,11 @@ func LexExpectNewline(_ *LexContext, l *lexer.Lexer) LexFn {
 }
 
 // LexMaybeNewline eats current whitespace, then emits either TokenNewline or TokenNotNewline
+// EOF is treated as a newline.
 //
 func LexMaybeNewline(_ *LexContext, l *lexer.Lexer) LexFn {
 	ignoreSpace(l)
-	if matchNewline(l) {
+	if matchNewlineOrEOF(l) {
 		l.EmitType(TokenNewline)
 	} else {
 		l.EmitType(TokenNotNewline)
@@ -1037,9 +1736,9 @@ func matchDotID(l *lexer.Lexer) (ok bool) {
 		}
 	}()
 	if matchRune(l, runeDot) && matchOne(l, isAlpha) {
-		matchZeroOrMore(l, isAlphaNum)
+		matchZeroOrMore(l, isAlphaNumUnder)
 		for matchRune(l, runeDot) {
-			if !matchOneOrMore(l, isAlphaNum) {
+			if !matchOneOrMore(l, isAlphaNumUnder) {
 				return ok
 			}
 		}
@@ -1060,6 +1759,28 @@ func matchDashID(l *lexer.Lexer) bool {
 	return matchOne(l, isAlphaUnder) && matchZeroOrMore(l, isAlphaNumUnderDash)
 }
 
+// matchKeyword matches a (case-insensitive) keyword, only if followed by a space or tab.
+// The trailing space is not consumed.
+//
+func matchKeyword(l *lexer.Lexer, keyword string) bool {
+	runes := []rune(keyword)
+	if !l.CanPeek(len(runes) + 1) {
+		return false
+	}
+	for i, r := range runes {
+		if unicode.ToUpper(l.Peek(i+1)) != r {
+			return false
+		}
+	}
+	if !isSpaceOrTab(l.Peek(len(runes) + 1)) {
+		return false
+	}
+	for range runes {
+		l.Next()
+	}
+	return true
+}
+
 // matchCommandDefID matches [.!]? DASH_ID
 // Used when defining a command, leading [.!] not needed when referencing it later
 //
@@ -1067,6 +1788,55 @@ func matchCommandDefID(l *lexer.Lexer) bool {
 	return matchZeroOrOne(l, isDotOrBang) && matchDashID(l)
 }
 
+// matchCommandPathID matches [.!]? DASH_ID ( '/' DASH_ID )+
+// Used when defining a sub-command, i.e. 'db/migrate'
+//
+func matchCommandPathID(l *lexer.Lexer) (ok bool) {
+	m := l.Marker()
+	// If we don't match then reset
+	//
+	defer func() {
+		if !ok {
+			m.Apply()
+		}
+	}()
+	if matchZeroOrOne(l, isDotOrBang) && matchDashID(l) && matchRune(l, runeSlash) {
+		if !matchDashID(l) {
+			return ok
+		}
+		for matchRune(l, runeSlash) {
+			if !matchDashID(l) {
+				return ok
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// matchCommandRefID matches DASH_ID ( [:/] DASH_ID )*
+// Used when referencing a command, which may be namespaced and/or a sub-command
+//
+func matchCommandRefID(l *lexer.Lexer) (ok bool) {
+	m := l.Marker()
+	// If we don't match then reset
+	//
+	defer func() {
+		if !ok {
+			m.Apply()
+		}
+	}()
+	if matchDashID(l) {
+		for matchRune(l, runeColon) || matchRune(l, runeSlash) {
+			if !matchDashID(l) {
+				return ok
+			}
+		}
+		return true
+	}
+	return false
+}
+
 // matchConfigAttrID matches [a-zA-Z] [a-zA-Z0-9_]* ( \. [a-zA-Z0-9_]+ )*
 //
 func matchConfigAttrID(l *lexer.Lexer) (ok bool) {
@@ -16,7 +16,7 @@ const (
 	// NOTE: You probably want matchNewline()
 	// runeNewline   = '\n'
 	// runeReturn    = '\r'
-	// runeAt        = '@'
+	runeAt        = '@'
 	runeBang      = '!'
 	runeHash      = '#'
 	runeDollar    = '$'
@@ -26,6 +26,10 @@ const (
 	runeEquals    = '='
 	runeQMark     = '?'
 	runeColon     = ':'
+	runePlus      = '+'
+	runePercent   = '%'
+	runeSlash     = '/'
+	runeCaret     = '^'
 	runeBackSlash = '\\'
 	runeDQuote    = '"'
 	runeSQuote    = '\''
@@ -46,12 +50,34 @@ var (
 	singleTokens = []token.Type{TokenQMark, TokenColon, TokenEquals, TokenLParen, TokenRParen, TokenLBrace, TokenRBrace, TokenLBracket, TokenRBracket}
 )
 var mainTokens = map[string]token.Type{
-	"COMMAND":     TokenCommand,
-	"CMD":         TokenCommand,
-	"EXPORT":      TokenExport,
-	"ASSERT":      TokenAssert,
-	"INCLUDE":     TokenInclude,
-	"INCLUDE.ENV": TokenIncludeEnv,
+	"COMMAND":      TokenCommand,
+	"CMD":          TokenCommand,
+	"EXPORT":       TokenExport,
+	"ASSERT":       TokenAssert,
+	"INCLUDE":      TokenInclude,
+	"INCLUDE.ENV":  TokenIncludeEnv,
+	"INCLUDE.VARS": TokenIncludeVars,
+	"BEFORE.ALL":   TokenBeforeAll,
+	"AFTER.ALL":    TokenAfterAll,
+	"OVERRIDE":     TokenOverride,
+	"OPTIONS":      TokenOptions,
+	"OPTION":       TokenOption,
+	"IF":           TokenIf,
+	"ELSE":         TokenElse,
+	"END":          TokenEnd,
+}
+
+// softMainTokens are keywords that were introduced after runfiles could already use them as names.
+// They are only treated as keywords if the rest of the line is not a command definition or assignment,
+// i.e. 'options:' and 'END := 1' remain valid.
+//
+var softMainTokens = map[string]struct{}{
+	"OVERRIDE": {},
+	"OPTIONS":  {},
+	"OPTION":   {},
+	"IF":       {},
+	"ELSE":     {},
+	"END":      {},
 }
 
 // isMainToken isolates the lookup+check-ok logic.
@@ -62,19 +88,42 @@ func isMainToken(s string) bool {
 	return ok
 }
 
+// softCmdConfigTokens are doc block keywords that were introduced after doc blocks could already use them as description text,
+// i.e. '# Group related targets.' or '# Default behaviour is to build everything.'
+// Within the description, they are only treated as keywords if upper-case, and the rest of the line matches the attribute.
+//
+var softCmdConfigTokens = map[string]struct{}{
+	"OPTIONS":          {},
+	"RUN.SUPER":        {},
+	"RUN.EACH":         {},
+	"FOREACH":          {},
+	"FOREACH.PARALLEL": {},
+	"ALIAS":            {},
+	"GROUP":            {},
+	"DEFAULT":          {},
+}
+
 // Cmd Config Tokens
 //
 var cmdConfigTokens = map[string]token.Type{
-	"SHELL":      TokenConfigShell,
-	"USAGE":      TokenConfigUsage,
-	"OPTION":     TokenConfigOpt,
-	"OPT":        TokenConfigOpt,
-	"EXPORT":     TokenConfigExport,
-	"ASSERT":     TokenConfigAssert,
-	"RUN":        TokenConfigRunBefore,
-	"RUN.BEFORE": TokenConfigRunBefore,
-	"RUN.AFTER":  TokenConfigRunAfter,
-	"RUN.ENV":    TokenConfigRunEnv,
+	"SHELL":            TokenConfigShell,
+	"USAGE":            TokenConfigUsage,
+	"OPTION":           TokenConfigOpt,
+	"OPT":              TokenConfigOpt,
+	"OPTIONS":          TokenConfigOptions,
+	"EXPORT":           TokenConfigExport,
+	"ASSERT":           TokenConfigAssert,
+	"RUN":              TokenConfigRunBefore,
+	"RUN.BEFORE":       TokenConfigRunBefore,
+	"RUN.AFTER":        TokenConfigRunAfter,
+	"RUN.ENV":          TokenConfigRunEnv,
+	"RUN.SUPER":        TokenConfigRunSuper,
+	"RUN.EACH":         TokenConfigRunEach,
+	"FOREACH":          TokenConfigForeach,
+	"FOREACH.PARALLEL": TokenConfigParallel,
+	"ALIAS":            TokenConfigAlias,
+	"GROUP":            TokenConfigGroup,
+	"DEFAULT":          TokenConfigDefault,
 }
 
 func isAlpha(r rune) bool {
@@ -131,6 +180,10 @@ func isPrintNonReturn(r rune) bool {
 	return unicode.IsPrint(r) && r != '\r' && r != '\n'
 }
 
+func isNonReturn(r rune) bool {
+	return r != '\r' && r != '\n'
+}
+
 func isConfigOptExample(r rune) bool {
 	return unicode.IsPrint(r) && r != '\r' && r != '\n' && r != '\t' && r != '<' && r != '>'
 }
@@ -159,6 +212,34 @@ func isPrintNonBackslashNonDollarNonReturn(r rune) bool {
 	return r != runeBackSlash && r != runeDollar && isPrintNonReturn(r)
 }
 
+func isFnArgRune(r rune) bool {
+	return r != runeRParen && r != runeBackSlash && r != runeDollar && r != runeDQuote && r != runeSQuote && r != ' ' && unicode.IsPrint(r)
+}
+
+func isListItemRune(r rune) bool {
+	return r != runeRBracket && r != runeBackSlash && r != runeDollar && r != runeDQuote && r != runeSQuote && !unicode.IsSpace(r) && unicode.IsPrint(r)
+}
+
+func isListIndexRune(r rune) bool {
+	return r != runeRBracket && r != runeRBrace && !unicode.IsSpace(r) && unicode.IsPrint(r)
+}
+
+func isIfValueRune(r rune) bool {
+	return r != runeDollar && r != runeDQuote && r != runeSQuote && !unicode.IsSpace(r) && unicode.IsPrint(r)
+}
+
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
+
+func isPrintNonRBraceNonBackslashNonDollar(r rune) bool {
+	return r != runeRBrace && r != runeBackSlash && r != runeDollar && unicode.IsPrint(r)
+}
+
+func isPrintNonRBraceNonBackslashNonDollarNonSlash(r rune) bool {
+	return r != runeSlash && isPrintNonRBraceNonBackslashNonDollar(r)
+}
+
 // tryPeekRune tries to peek the next rune
 //
 func tryPeekRune(l *lexer.Lexer) (rune, bool) {
@@ -17,12 +17,16 @@ const (
 	TokenCommandDefID
 
 	// TokenAt          // '@'
-	TokenBang        // '!'
-	TokenColon       // ':'
-	TokenComma       // ','
-	TokenQMark       // '?'
-	TokenEquals      // '=' | ':='
-	TokenQMarkEquals // ?=
+	TokenBang         // '!'
+	TokenColon        // ':'
+	TokenComma        // ','
+	TokenQMark        // '?'
+	TokenEquals       // '=' | ':='
+	TokenQMarkEquals  // ?=
+	TokenPlusEquals   // +=
+	TokenBangEquals   // !=
+	TokenDColonEquals // ::=
+	TokenEqualsEquals // ==
 
 	TokenDQuote // '"'
 	TokenDQStringStart
@@ -37,6 +41,18 @@ const (
 	TokenVarRefStart
 	TokenSubCmdStart
 
+	TokenVarLength // '#' in '${#NAME}'
+	TokenVarOp     // ':-' | ':=' | ':?' | ':+' | ':' | '#' | '%' | '/' | etc
+	TokenVarOpSep  // '/' in '${NAME/pattern/replacement}'
+	TokenVarIndex  // '@' | number in '${NAME[index]}'
+
+	TokenFnName   // 'name' in '$(fn:name args)'
+	TokenFnArgSep // Whitespace between function args
+
+	TokenListItemSep // Whitespace between list items
+
+	TokenIfValueEnd // End of an IF comparison value
+
 	TokenLParen   // '('
 	TokenRParen   // ')'
 	TokenLBrace   // '{'
@@ -56,10 +72,22 @@ const (
 
 	TokenExport
 	TokenAs
+	TokenIn
+	TokenExpand
+	TokenFrom
 	TokenAssert
 	TokenInclude
 	TokenIncludeEnv
+	TokenIncludeVars
+	TokenBeforeAll
+	TokenAfterAll
+	TokenOverride
+	TokenOptions
+	TokenOption
 	TokenCommand
+	TokenIf
+	TokenElse
+	TokenEnd
 
 	TokenHashLine
 
@@ -77,6 +105,14 @@ const (
 	TokenConfigRunBefore
 	TokenConfigRunAfter
 	TokenConfigRunEnv
+	TokenConfigAlias
+	TokenConfigGroup
+	TokenConfigDefault
+	TokenConfigRunSuper
+	TokenConfigRunEach
+	TokenConfigForeach
+	TokenConfigParallel
+	TokenConfigOptions
 
 	TokenConfigEnd
 
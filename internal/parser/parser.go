@@ -19,7 +19,7 @@ import (
 // ParseBytes attempts to parse the specified byte array.
 //
 func ParseBytes(runfile []byte) *ast.Ast {
-	return Parse(lexer.Lex(runfile))
+	return Parse(lexer.Lex(runfile, ast.IsFnName))
 }
 
 // parseFn
@@ -33,6 +33,16 @@ type parseContext struct {
 	ast     *ast.Ast
 	fn      parseFn
 	fnStack *list.List
+	blocks  *list.List // Open IF blocks
+}
+
+// ifBlock tracks an open IF block, along with the ast to restore when the block ends.
+//
+type ifBlock struct {
+	node   *ast.IfBlock
+	parent *ast.Ast
+	start  token.Token
+	inElse bool
 }
 
 // parse
@@ -82,11 +92,17 @@ func Parse(l *lexer.LexContext) *ast.Ast {
 		ast:     ast.NewAST(),
 		fn:      parseMain,
 		fnStack: list.New(),
+		blocks:  list.New(),
 	}
 	_, err := parser.Parse(l.Tokens, ctx.parse).Next() // No emits
 	if err != nil && err != io.EOF {
 		panic(err)
 	}
+	// All IF blocks must be closed
+	//
+	if ctx.blocks.Len() > 0 {
+		panic(tokenError(ctx.blocks.Back().Value.(*ifBlock).start, "IF without matching END"))
+	}
 	return ctx.ast
 }
 
@@ -118,21 +134,43 @@ func parseMain(ctx *parseContext, p *parser.Parser) parseFn {
 		for hasNext := true; hasNext; {
 			hasNext = false
 			if tryPeekType(p, lexer.TokenID) {
-				varName = p.Next().Value()
+				t := p.Next()
+				varName = t.Value()
 				switch {
 				// '=' | ':=''
 				//
 				case !commaMode && tryPeekType(p, lexer.TokenEquals):
 					p.Next()
 					valueList = expectAssignmentValue(ctx, p)
-					ctx.ast.AddScopeNode(&ast.ScopeVarAssignment{Name: varName, Value: valueList})
+					ctx.ast.AddScopeNode(&ast.ScopeVarAssignment{Name: varName, Value: valueList, Runfile: config.CurrentRunfile, Line: t.Line()})
 					ctx.ast.AddScopeNode(ast.NewVarExport(varName))
 				// '?='
 				//
 				case !commaMode && tryPeekType(p, lexer.TokenQMarkEquals):
 					p.Next()
 					valueList = expectAssignmentValue(ctx, p)
-					ctx.ast.AddScopeNode(&ast.ScopeVarQAssignment{Name: varName, Value: valueList})
+					ctx.ast.AddScopeNode(&ast.ScopeVarQAssignment{Name: varName, Value: valueList, Runfile: config.CurrentRunfile, Line: t.Line()})
+					ctx.ast.AddScopeNode(ast.NewVarExport(varName))
+				// '+='
+				//
+				case !commaMode && tryPeekType(p, lexer.TokenPlusEquals):
+					p.Next()
+					valueList = expectAssignmentValue(ctx, p)
+					ctx.ast.AddScopeNode(&ast.ScopeVarAppend{Name: varName, Value: valueList, Runfile: config.CurrentRunfile, Line: t.Line()})
+					ctx.ast.AddScopeNode(ast.NewVarExport(varName))
+				// '!='
+				//
+				case !commaMode && tryPeekType(p, lexer.TokenBangEquals):
+					p.Next()
+					valueList = expectShellAssignmentValue(ctx, p, t)
+					ctx.ast.AddScopeNode(&ast.ScopeVarAssignment{Name: varName, Value: valueList, Runfile: config.CurrentRunfile, Line: t.Line()})
+					ctx.ast.AddScopeNode(ast.NewVarExport(varName))
+				// '::='
+				//
+				case !commaMode && tryPeekType(p, lexer.TokenDColonEquals):
+					p.Next()
+					valueList = expectAssignmentValue(ctx, p)
+					ctx.ast.AddScopeNode(&ast.ScopeVarReadonlyAssignment{Name: varName, Value: valueList, Runfile: config.CurrentRunfile, Line: t.Line()})
 					ctx.ast.AddScopeNode(ast.NewVarExport(varName))
 				// Export existing variable
 				//
@@ -169,6 +207,58 @@ func parseMain(ctx *parseContext, p *parser.Parser) parseFn {
 		p.Clear()
 		return parseMain
 	}
+	// If
+	//
+	if tryPeekType(p, lexer.TokenIf) {
+		t := p.Next()
+		ctx.pushLexFn(ctx.l.Fn)
+		ctx.pushLexFn(lexer.LexExpectNewline)
+		ctx.setLexFn(lexer.LexIf)
+		node := &ast.IfBlock{
+			Runfile: config.CurrentRunfile,
+			Line:    t.Line(),
+			Cond:    expectIfCondition(ctx, p),
+			Then:    ast.NewAST(),
+			Else:    ast.NewAST(),
+		}
+		expectTokenType(p, lexer.TokenNewline, "expecting end of line")
+		p.Clear()
+		ctx.ast.Add(node)
+		ctx.blocks.PushBack(&ifBlock{node: node, parent: ctx.ast, start: t})
+		ctx.ast = node.Then
+		return parseMain
+	}
+	// Else
+	//
+	if tryPeekType(p, lexer.TokenElse) {
+		t := p.Next()
+		if ctx.blocks.Len() == 0 || ctx.blocks.Back().Value.(*ifBlock).inElse {
+			panic(tokenError(t, "ELSE without matching IF"))
+		}
+		ctx.pushLexFn(ctx.l.Fn)
+		ctx.setLexFn(lexer.LexExpectNewline)
+		expectTokenType(p, lexer.TokenNewline, "expecting end of line")
+		p.Clear()
+		block := ctx.blocks.Back().Value.(*ifBlock)
+		block.inElse = true
+		ctx.ast = block.node.Else
+		return parseMain
+	}
+	// End
+	//
+	if tryPeekType(p, lexer.TokenEnd) {
+		t := p.Next()
+		if ctx.blocks.Len() == 0 {
+			panic(tokenError(t, "END without matching IF"))
+		}
+		ctx.pushLexFn(ctx.l.Fn)
+		ctx.setLexFn(lexer.LexExpectNewline)
+		expectTokenType(p, lexer.TokenNewline, "expecting end of line")
+		p.Clear()
+		block := ctx.blocks.Remove(ctx.blocks.Back()).(*ifBlock)
+		ctx.ast = block.parent
+		return parseMain
+	}
 	// Assert
 	//
 	if tryPeekType(p, lexer.TokenAssert) {
@@ -198,7 +288,15 @@ func parseMain(ctx *parseContext, p *parser.Parser) parseFn {
 			missingMatchersOk = t.Type() != lexer.TokenBang
 		)
 		valueList = expectAssignmentValue(ctx, p)
-		ctx.ast.Add(&ast.ScopeInclude{FilePattern: valueList, MissingSingleOk: missingSingleOk, MissingMatchersOk: missingMatchersOk})
+		// AS <namespace> ?
+		//
+		var namespace string
+		ctx.setLexFn(lexer.LexIncludeAs)
+		if tryPeekType(p, lexer.TokenAs) {
+			p.Next()
+			namespace = expectTokenType(p, lexer.TokenDashID, "expecting namespace").Value()
+		}
+		ctx.ast.Add(&ast.ScopeInclude{FilePattern: valueList, Namespace: namespace, MissingSingleOk: missingSingleOk, MissingMatchersOk: missingMatchersOk})
 		expectTokenType(p, lexer.TokenNewline, "expecting end of line")
 		p.Clear()
 		return parseMain
@@ -206,6 +304,50 @@ func parseMain(ctx *parseContext, p *parser.Parser) parseFn {
 	// Include.Env
 	//
 	if tryPeekType(p, lexer.TokenIncludeEnv) {
+		p.Next()
+		ctx.pushLexFn(ctx.l.Fn)
+		ctx.setLexFn(lexer.LexIncludeEnvProfiles)
+		// [ profile, ... ] ?
+		//
+		var profiles []string
+		if tryPeekType(p, lexer.TokenLBracket) {
+			p.Next()
+			for hasNext := true; hasNext; {
+				profiles = append(profiles, expectTokenType(p, lexer.TokenDashID, "expecting profile name").Value())
+				if hasNext = tryPeekType(p, lexer.TokenComma); hasNext {
+					p.Next()
+				}
+			}
+			expectTokenType(p, lexer.TokenRBracket, "expecting right-bracket (']')")
+		}
+		t := p.Next()
+		var (
+			missingSingleOk   = t.Type() != lexer.TokenBang
+			missingMatchersOk = t.Type() != lexer.TokenBang
+		)
+		// EXPORT | EXPAND ?
+		//
+		var export, expand bool
+		for hasNext := true; hasNext; {
+			ctx.setLexFn(lexer.LexIncludeEnvModifier)
+			switch p.Next().Type() {
+			case lexer.TokenExport:
+				export = true
+			case lexer.TokenExpand:
+				expand = true
+			default:
+				hasNext = false
+			}
+		}
+		valueList = expectAssignmentValue(ctx, p)
+		ctx.ast.Add(&ast.ScopeIncludeEnv{FilePattern: valueList, Profiles: profiles, Export: export, Expand: expand, MissingSingleOk: missingSingleOk, MissingMatchersOk: missingMatchersOk})
+		expectTokenType(p, lexer.TokenNewline, "expecting end of line")
+		p.Clear()
+		return parseMain
+	}
+	// Include.Vars
+	//
+	if tryPeekType(p, lexer.TokenIncludeVars) {
 		p.Next()
 		ctx.pushLexFn(ctx.l.Fn)
 		ctx.setLexFn(lexer.LexMaybeBangOrQMark)
@@ -215,7 +357,53 @@ func parseMain(ctx *parseContext, p *parser.Parser) parseFn {
 			missingMatchersOk = t.Type() != lexer.TokenBang
 		)
 		valueList = expectAssignmentValue(ctx, p)
-		ctx.ast.Add(&ast.ScopeIncludeEnv{FilePattern: valueList, MissingSingleOk: missingSingleOk, MissingMatchersOk: missingMatchersOk})
+		// FROM <key.path> ?
+		//
+		var keyPath string
+		ctx.setLexFn(lexer.LexIncludeVarsFrom)
+		if tryPeekType(p, lexer.TokenFrom) {
+			p.Next()
+			keyPath = expectTokenType(p, lexer.TokenRunes, "expecting key path").Value()
+		}
+		ctx.ast.Add(&ast.ScopeIncludeVars{FilePattern: valueList, KeyPath: keyPath, MissingSingleOk: missingSingleOk, MissingMatchersOk: missingMatchersOk})
+		expectTokenType(p, lexer.TokenNewline, "expecting end of line")
+		p.Clear()
+		return parseMain
+	}
+	// Before.All / After.All
+	//
+	if tryPeekType(p, lexer.TokenBeforeAll) || tryPeekType(p, lexer.TokenAfterAll) {
+		t := p.Next()
+		ctx.pushLexFn(ctx.l.Fn)
+		ctx.setLexFn(lexer.LexExpectCommandName)
+		commandToken := expectTokenType(p, lexer.TokenDashID, "expecting command name")
+		command := commandToken.Value()
+		// Hooks are not defined within a command, so there is no overridden command to invoke
+		//
+		if command == runfile.SuperCommand {
+			hookName := "BEFORE.ALL"
+			if t.Type() == lexer.TokenAfterAll {
+				hookName = "AFTER.ALL"
+			}
+			panic(tokenError(commandToken, fmt.Sprintf("%s cannot invoke the overridden command (%s)", hookName, runfile.SuperCommand)))
+		}
+		var patterns []ast.ScopeValueNode
+		for {
+			ctx.setLexFn(lexer.LexMaybeNewline)
+			if tryPeekType(p, lexer.TokenNotNewline) {
+				p.Next()
+				patterns = append(patterns, expectAssignmentValue(ctx, p))
+			} else {
+				break
+			}
+		}
+		ctx.ast.Add(&ast.RunfileHook{
+			After:    t.Type() == lexer.TokenAfterAll,
+			Command:  command,
+			Patterns: patterns,
+			Runfile:  config.CurrentRunfile,
+			Line:     t.Line(),
+		})
 		expectTokenType(p, lexer.TokenNewline, "expecting end of line")
 		p.Clear()
 		return parseMain
@@ -236,11 +424,33 @@ func parseMain(ctx *parseContext, p *parser.Parser) parseFn {
 	// Doc Block
 	//
 	if cmdConfig, ok = tryMatchDocBlock(ctx, p); ok {
+		// Option Set?
+		//
+		if tryPeekType(p, lexer.TokenOptions) {
+			expectOptionSet(ctx, p, cmdConfig)
+			return parseMain
+		}
 		// Command?
 		//
 		tryMatchCmd(ctx, p, cmdConfig)
 		return parseMain
 	}
+	// Option Set without doc block
+	//
+	if tryPeekType(p, lexer.TokenOptions) {
+		panic(parseError(p, "OPTIONS must follow a doc block defining the options"))
+	}
+	// Global Option
+	//
+	if tryPeekType(p, lexer.TokenOption) {
+		t := p.Next()
+		ctx.ast.Add(&ast.GlobalOpt{
+			Opt:     expectCmdOpt(ctx, p),
+			Runfile: config.CurrentRunfile,
+			Line:    t.Line(),
+		})
+		return parseMain
+	}
 	// DotAssignment
 	//
 	if attrName, ok = tryMatchDotAssignmentStart(p); ok {
@@ -254,18 +464,42 @@ func parseMain(ctx *parseContext, p *parser.Parser) parseFn {
 	}
 	// Variable Assignment
 	//
-	if varName, ok = tryMatchAssignmentStart(p); ok {
+	if t, ok := tryMatchAssignmentStart(p); ok {
 		ctx.pushLexFn(ctx.l.Fn)
 		valueList = expectAssignmentValue(ctx, p)
-		ctx.ast.AddScopeNode(&ast.ScopeVarAssignment{Name: varName, Value: valueList})
+		ctx.ast.AddScopeNode(&ast.ScopeVarAssignment{Name: t.Value(), Value: valueList, Runfile: config.CurrentRunfile, Line: t.Line()})
 		return parseMain
 	}
 	// Variable QAssignment
 	//
-	if varName, ok = tryMatchQAssignmentStart(p); ok {
+	if t, ok := tryMatchQAssignmentStart(p); ok {
+		ctx.pushLexFn(ctx.l.Fn)
+		valueList = expectAssignmentValue(ctx, p)
+		ctx.ast.AddScopeNode(&ast.ScopeVarQAssignment{Name: t.Value(), Value: valueList, Runfile: config.CurrentRunfile, Line: t.Line()})
+		return parseMain
+	}
+	// Variable Append
+	//
+	if t, ok := tryMatchAppendStart(p); ok {
+		ctx.pushLexFn(ctx.l.Fn)
+		valueList = expectAssignmentValue(ctx, p)
+		ctx.ast.AddScopeNode(&ast.ScopeVarAppend{Name: t.Value(), Value: valueList, Runfile: config.CurrentRunfile, Line: t.Line()})
+		return parseMain
+	}
+	// Variable Shell Assignment
+	//
+	if t, ok := tryMatchAssignmentOpStart(p, lexer.TokenBangEquals, "expecting TokenBangEquals ('!=')"); ok {
+		ctx.pushLexFn(ctx.l.Fn)
+		valueList = expectShellAssignmentValue(ctx, p, t)
+		ctx.ast.AddScopeNode(&ast.ScopeVarAssignment{Name: t.Value(), Value: valueList, Runfile: config.CurrentRunfile, Line: t.Line()})
+		return parseMain
+	}
+	// Variable Readonly Assignment
+	//
+	if t, ok := tryMatchAssignmentOpStart(p, lexer.TokenDColonEquals, "expecting TokenDColonEquals ('::=')"); ok {
 		ctx.pushLexFn(ctx.l.Fn)
 		valueList = expectAssignmentValue(ctx, p)
-		ctx.ast.AddScopeNode(&ast.ScopeVarQAssignment{Name: varName, Value: valueList})
+		ctx.ast.AddScopeNode(&ast.ScopeVarReadonlyAssignment{Name: t.Value(), Value: valueList, Runfile: config.CurrentRunfile, Line: t.Line()})
 		return parseMain
 	}
 	// Command
@@ -324,6 +558,60 @@ func tryMatchCmd(ctx *parseContext, p *parser.Parser, cmdConfig *ast.CmdConfig)
 	return true
 }
 
+// expectOptionSet matches an option set definition: OPTIONS <name>
+// The preceding doc block defines the options and may only contain OPTION attributes.
+//
+func expectOptionSet(ctx *parseContext, p *parser.Parser, cmdConfig *ast.CmdConfig) {
+	t := expectTokenType(p, lexer.TokenOptions, "expecting TokenOptions")
+	if !tryPeekType(p, lexer.TokenID) && !tryPeekType(p, lexer.TokenDashID) {
+		panic(parseError(p, "expecting option set name"))
+	}
+	name := p.Next().Value()
+	expectTokenType(p, lexer.TokenNewline, "expecting end of line")
+	p.Clear()
+	if !cmdConfig.OnlyOpts() {
+		panic(tokenError(t, fmt.Sprintf("option set '%s' can only contain OPTION attributes", name)))
+	}
+	if len(cmdConfig.Opts) == 0 {
+		panic(tokenError(t, fmt.Sprintf("option set '%s' does not define any options", name)))
+	}
+	ctx.ast.Add(&ast.OptionSet{
+		Name:    name,
+		Opts:    cmdConfig.Opts,
+		Runfile: config.CurrentRunfile,
+		Line:    t.Line(),
+	})
+}
+
+// expectCmdOpt expects an option definition, following the OPTION keyword.
+// Used for both doc block and global options.
+//
+func expectCmdOpt(ctx *parseContext, p *parser.Parser) *ast.CmdOpt {
+	opt := &ast.CmdOpt{}
+	ctx.pushLexFn(ctx.l.Fn)
+	ctx.setLexFn(lexer.LexCmdConfigOpt)
+	opt.Name = expectTokenType(p, lexer.TokenConfigOptName, "expecting TokenConfigOptName").Value()
+	if tryPeekType(p, lexer.TokenBang) {
+		opt.Required = true
+		p.Next()
+	} else if tryPeekType(p, lexer.TokenQMarkEquals) {
+		p.Next()
+		ctx.pushLexFn(ctx.l.Fn)
+		opt.Default = expectAssignmentValue(ctx, p)
+	}
+	if tryPeekType(p, lexer.TokenConfigOptShort) {
+		opt.Short = []rune(p.Next().Value())[0]
+	}
+	if tryPeekType(p, lexer.TokenConfigOptLong) {
+		opt.Long = p.Next().Value()
+	}
+	if tryPeekType(p, lexer.TokenConfigOptExample) {
+		opt.Example = p.Next().Value()
+	}
+	opt.Desc = expectDocNQString(ctx, p)
+	return opt
+}
+
 // tryMatchDocBlock
 //
 func tryMatchDocBlock(ctx *parseContext, p *parser.Parser) (*ast.CmdConfig, bool) {
@@ -362,31 +650,51 @@ func tryMatchDocBlock(ctx *parseContext, p *parser.Parser) (*ast.CmdConfig, bool
 				usage := expectDocNQString(ctx, p)
 				cmdConfig.Usages = append(cmdConfig.Usages, usage)
 				p.Clear()
-			case lexer.TokenConfigOpt:
+			case lexer.TokenConfigGroup:
 				p.Next()
-				opt := &ast.CmdOpt{}
-				ctx.pushLexFn(ctx.l.Fn)
-				ctx.setLexFn(lexer.LexCmdConfigOpt)
-				opt.Name = expectTokenType(p, lexer.TokenConfigOptName, "expecting TokenConfigOptName").Value()
-				if tryPeekType(p, lexer.TokenBang) {
-					opt.Required = true
-					p.Next()
-				} else if tryPeekType(p, lexer.TokenQMarkEquals) {
-					p.Next()
-					ctx.pushLexFn(ctx.l.Fn)
-					opt.Default = expectAssignmentValue(ctx, p)
+				if cmdConfig.Group != nil {
+					panic(fmt.Sprintf("%d:%d: GROUP already defined", t.Line(), t.Column()))
 				}
-				if tryPeekType(p, lexer.TokenConfigOptShort) {
-					opt.Short = []rune(p.Next().Value())[0]
-				}
-				if tryPeekType(p, lexer.TokenConfigOptLong) {
-					opt.Long = p.Next().Value()
-				}
-				if tryPeekType(p, lexer.TokenConfigOptExample) {
-					opt.Example = p.Next().Value()
+				ctx.pushLexFn(ctx.l.Fn)
+				ctx.setLexFn(lexer.LexCmdConfigGroup)
+				cmdConfig.Group = expectDocNQString(ctx, p)
+				p.Clear()
+			case lexer.TokenConfigDefault:
+				p.Next()
+				ctx.pushLexFn(ctx.l.Fn)
+				ctx.setLexFn(lexer.LexExpectNewline)
+				cmdConfig.Default = true
+				expectTokenType(p, lexer.TokenNewline, "expecting end of line")
+				p.Clear()
+			case lexer.TokenConfigParallel:
+				p.Next()
+				ctx.pushLexFn(ctx.l.Fn)
+				ctx.setLexFn(lexer.LexExpectNewline)
+				cmdConfig.Parallel = true
+				expectTokenType(p, lexer.TokenNewline, "expecting end of line")
+				p.Clear()
+			case lexer.TokenConfigForeach:
+				p.Next()
+				ctx.pushLexFn(ctx.l.Fn)
+				ctx.setLexFn(lexer.LexForeach)
+				name := expectTokenType(p, lexer.TokenID, "expecting TokenID").Value()
+				expectTokenType(p, lexer.TokenIn, "expecting TokenIn ('in')")
+				var values []ast.ScopeValueNode
+				for {
+					ctx.setLexFn(lexer.LexMaybeNewline)
+					if tryPeekType(p, lexer.TokenNotNewline) {
+						p.Next()
+						values = append(values, expectAssignmentValue(ctx, p))
+					} else {
+						break
+					}
 				}
-				opt.Desc = expectDocNQString(ctx, p)
-				cmdConfig.Opts = append(cmdConfig.Opts, opt)
+				cmdConfig.Foreach = append(cmdConfig.Foreach, &ast.CmdForeach{Name: name, Values: values})
+				expectTokenType(p, lexer.TokenNewline, "expecting end of line")
+				p.Clear()
+			case lexer.TokenConfigOpt:
+				p.Next()
+				cmdConfig.Opts = append(cmdConfig.Opts, expectCmdOpt(ctx, p))
 			case lexer.TokenConfigExport:
 				p.Next()
 				ctx.pushLexFn(ctx.l.Fn)
@@ -396,21 +704,43 @@ func tryMatchDocBlock(ctx *parseContext, p *parser.Parser) (*ast.CmdConfig, bool
 				for hasNext := true; hasNext; {
 					hasNext = false
 					if tryPeekType(p, lexer.TokenID) {
-						varName := expectTokenType(p, lexer.TokenID, "expecting TokenID").Value()
+						t := expectTokenType(p, lexer.TokenID, "expecting TokenID")
+						varName := t.Value()
 						switch {
 						// '=' | ':=''
 						//
 						case !commaMode && tryPeekType(p, lexer.TokenEquals):
 							p.Next()
 							valueList := expectAssignmentValue(ctx, p)
-							cmdConfig.Vars = append(cmdConfig.Vars, &ast.ScopeVarAssignment{Name: varName, Value: valueList})
+							cmdConfig.Vars = append(cmdConfig.Vars, &ast.ScopeVarAssignment{Name: varName, Value: valueList, Runfile: config.CurrentRunfile, Line: t.Line()})
 							cmdConfig.VarExports = append(cmdConfig.VarExports, ast.NewVarExport(varName))
 						// '?='
 						//
 						case !commaMode && tryPeekType(p, lexer.TokenQMarkEquals):
 							p.Next()
 							valueList := expectAssignmentValue(ctx, p)
-							cmdConfig.Vars = append(cmdConfig.Vars, &ast.ScopeVarQAssignment{Name: varName, Value: valueList})
+							cmdConfig.Vars = append(cmdConfig.Vars, &ast.ScopeVarQAssignment{Name: varName, Value: valueList, Runfile: config.CurrentRunfile, Line: t.Line()})
+							cmdConfig.VarExports = append(cmdConfig.VarExports, ast.NewVarExport(varName))
+						// '+='
+						//
+						case !commaMode && tryPeekType(p, lexer.TokenPlusEquals):
+							p.Next()
+							valueList := expectAssignmentValue(ctx, p)
+							cmdConfig.Vars = append(cmdConfig.Vars, &ast.ScopeVarAppend{Name: varName, Value: valueList, Runfile: config.CurrentRunfile, Line: t.Line()})
+							cmdConfig.VarExports = append(cmdConfig.VarExports, ast.NewVarExport(varName))
+						// '!='
+						//
+						case !commaMode && tryPeekType(p, lexer.TokenBangEquals):
+							p.Next()
+							valueList := expectShellAssignmentValue(ctx, p, t)
+							cmdConfig.Vars = append(cmdConfig.Vars, &ast.ScopeVarAssignment{Name: varName, Value: valueList, Runfile: config.CurrentRunfile, Line: t.Line()})
+							cmdConfig.VarExports = append(cmdConfig.VarExports, ast.NewVarExport(varName))
+						// '::='
+						//
+						case !commaMode && tryPeekType(p, lexer.TokenDColonEquals):
+							p.Next()
+							valueList := expectAssignmentValue(ctx, p)
+							cmdConfig.Vars = append(cmdConfig.Vars, &ast.ScopeVarReadonlyAssignment{Name: varName, Value: valueList, Runfile: config.CurrentRunfile, Line: t.Line()})
 							cmdConfig.VarExports = append(cmdConfig.VarExports, ast.NewVarExport(varName))
 						// Export existing variable
 						//
@@ -446,6 +776,38 @@ func tryMatchDocBlock(ctx *parseContext, p *parser.Parser) (*ast.CmdConfig, bool
 				}
 				expectTokenType(p, lexer.TokenNewline, "expecting end of line")
 				p.Clear()
+			case lexer.TokenConfigAlias:
+				p.Next()
+				ctx.pushLexFn(ctx.l.Fn)
+				ctx.pushLexFn(lexer.LexExpectNewline)
+				ctx.setLexFn(lexer.LexCmdConfigAlias)
+				for hasNext := true; hasNext; {
+					alias := expectTokenType(p, lexer.TokenDashID, "expecting alias name").Value()
+					cmdConfig.Aliases = append(cmdConfig.Aliases, alias)
+					// ','
+					//
+					if hasNext = tryPeekType(p, lexer.TokenComma); hasNext {
+						p.Next()
+					}
+				}
+				expectTokenType(p, lexer.TokenNewline, "expecting end of line")
+				p.Clear()
+			case lexer.TokenConfigOptions:
+				p.Next()
+				ctx.pushLexFn(ctx.l.Fn)
+				ctx.pushLexFn(lexer.LexExpectNewline)
+				ctx.setLexFn(lexer.LexCmdConfigOptions)
+				for hasNext := true; hasNext; {
+					name := expectTokenType(p, lexer.TokenDashID, "expecting option set name").Value()
+					cmdConfig.OptionSets = append(cmdConfig.OptionSets, name)
+					// ','
+					//
+					if hasNext = tryPeekType(p, lexer.TokenComma); hasNext {
+						p.Next()
+					}
+				}
+				expectTokenType(p, lexer.TokenNewline, "expecting end of line")
+				p.Clear()
 			case lexer.TokenConfigAssert:
 				t = p.Next()
 				ctx.pushLexFn(ctx.l.Fn)
@@ -459,11 +821,16 @@ func tryMatchDocBlock(ctx *parseContext, p *parser.Parser) (*ast.CmdConfig, bool
 				cmdConfig.Asserts = append(cmdConfig.Asserts, assert)
 				expectTokenType(p, lexer.TokenNewline, "expecting end of line")
 				p.Clear()
-			case lexer.TokenConfigRunEnv, lexer.TokenConfigRunBefore, lexer.TokenConfigRunAfter:
+			case lexer.TokenConfigRunEnv, lexer.TokenConfigRunBefore, lexer.TokenConfigRunAfter, lexer.TokenConfigRunSuper, lexer.TokenConfigRunEach:
 				t = p.Next()
 				ctx.pushLexFn(ctx.l.Fn)
-				ctx.setLexFn(lexer.LexExpectCommandName)
-				command := p.Next().Value()
+				// RUN.SUPER == RUN ^
+				//
+				command := runfile.SuperCommand
+				if t.Type() != lexer.TokenConfigRunSuper {
+					ctx.setLexFn(lexer.LexExpectCommandName)
+					command = expectTokenType(p, lexer.TokenDashID, "expecting command name").Value()
+				}
 				var args []ast.ScopeValueNode
 				for {
 					ctx.setLexFn(lexer.LexMaybeNewline)
@@ -474,11 +841,11 @@ func tryMatchDocBlock(ctx *parseContext, p *parser.Parser) (*ast.CmdConfig, bool
 						break
 					}
 				}
-				cmdRun := &ast.CmdRun{Command: command, Args: args}
+				cmdRun := &ast.CmdRun{Command: command, Args: args, Each: t.Type() == lexer.TokenConfigRunEach}
 				switch t.Type() {
 				case lexer.TokenConfigRunEnv:
 					cmdConfig.EnvRuns = append(cmdConfig.EnvRuns, cmdRun)
-				case lexer.TokenConfigRunBefore:
+				case lexer.TokenConfigRunBefore, lexer.TokenConfigRunSuper, lexer.TokenConfigRunEach:
 					cmdConfig.BeforeRuns = append(cmdConfig.BeforeRuns, cmdRun)
 				case lexer.TokenConfigRunAfter:
 					cmdConfig.AfterRuns = append(cmdConfig.AfterRuns, cmdRun)
@@ -519,6 +886,11 @@ func expectDocNQString(ctx *parseContext, p *parser.Parser) ast.ScopeValueNode {
 		case lexer.TokenVarRefStart:
 			p.Next()
 			values = append(values, expectVarRef(ctx, p))
+		// Function call
+		//
+		case lexer.TokenSubCmdStart:
+			p.Next()
+			values = append(values, expectSubCmd(ctx, p))
 		// End of line
 		//
 		default:
@@ -549,31 +921,103 @@ func tryMatchDotAssignmentStart(p *parser.Parser) (string, bool) {
 }
 
 // tryMatchAssignmentStart
+// Returns the variable name token.
 //
-func tryMatchAssignmentStart(p *parser.Parser) (string, bool) {
+func tryMatchAssignmentStart(p *parser.Parser) (token.Token, bool) {
 	if p.CanPeek(2) &&
 		p.PeekType(1) == lexer.TokenID &&
 		p.PeekType(2) == lexer.TokenEquals {
-		name := p.Next().Value()
+		t := p.Next()
 		expectTokenType(p, lexer.TokenEquals, "expecting TokenEquals ('=' | ':=')")
 		p.Clear()
-		return name, true
+		return t, true
 	}
-	return "", false
+	return nil, false
 }
 
 // tryMatchQAssignmentStart
+// Returns the variable name token.
 //
-func tryMatchQAssignmentStart(p *parser.Parser) (string, bool) {
+func tryMatchQAssignmentStart(p *parser.Parser) (token.Token, bool) {
 	if p.CanPeek(2) &&
 		p.PeekType(1) == lexer.TokenID &&
 		p.PeekType(2) == lexer.TokenQMarkEquals {
-		name := p.Next().Value()
+		t := p.Next()
 		expectTokenType(p, lexer.TokenQMarkEquals, "expecting TokenQMarkEquals ('?=')")
 		p.Clear()
-		return name, true
+		return t, true
 	}
-	return "", false
+	return nil, false
+}
+
+// tryMatchAppendStart
+// Returns the variable name token.
+//
+func tryMatchAppendStart(p *parser.Parser) (token.Token, bool) {
+	if p.CanPeek(2) &&
+		p.PeekType(1) == lexer.TokenID &&
+		p.PeekType(2) == lexer.TokenPlusEquals {
+		t := p.Next()
+		expectTokenType(p, lexer.TokenPlusEquals, "expecting TokenPlusEquals ('+=')")
+		p.Clear()
+		return t, true
+	}
+	return nil, false
+}
+
+// tryMatchAssignmentOpStart matches a variable name followed by the specified assignment operator.
+// Returns the variable name token.
+//
+func tryMatchAssignmentOpStart(p *parser.Parser, op token.Type, expecting string) (token.Token, bool) {
+	if p.CanPeek(2) &&
+		p.PeekType(1) == lexer.TokenID &&
+		p.PeekType(2) == op {
+		t := p.Next()
+		expectTokenType(p, op, expecting)
+		p.Clear()
+		return t, true
+	}
+	return nil, false
+}
+
+// expectShellAssignmentValue expects the command of a shell assignment ('!='), up to the end of the line.
+// The command output becomes the value, with newlines replaced by spaces.
+//
+func expectShellAssignmentValue(ctx *parseContext, p *parser.Parser, start token.Token) *ast.ScopeValueNodeList {
+	ctx.setLexFn(lexer.LexShellAssignmentValue)
+	values := make([]ast.ScopeValueNode, 0)
+	for hasNext := true; hasNext && p.CanPeek(1); {
+		switch p.PeekType(1) {
+		// Character run
+		//
+		case lexer.TokenRunes:
+			values = append(values, &ast.ScopeValueRunes{Value: p.Next().Value()})
+		// Escape char
+		//
+		case lexer.TokenEscapeSequence:
+			values = append(values, &ast.ScopeValueEsc{Seq: p.Next().Value()})
+		// Var Ref
+		//
+		case lexer.TokenVarRefStart:
+			p.Next()
+			values = append(values, expectVarRef(ctx, p))
+		// Function call
+		//
+		case lexer.TokenSubCmdStart:
+			p.Next()
+			values = append(values, expectSubCmd(ctx, p))
+		// End of line
+		//
+		default:
+			hasNext = false
+		}
+	}
+	return ast.NewScopeValueNodeList1(&ast.ScopeValueShell{
+		Runfile:   config.CurrentRunfile,
+		Line:      start.Line(),
+		Cmd:       ast.NewScopeValueNodeList(values),
+		JoinLines: true,
+	})
 }
 
 // expectAssignmentValue
@@ -596,6 +1040,9 @@ func expectAssignmentValue(ctx *parseContext, p *parser.Parser) *ast.ScopeValueN
 	case lexer.TokenSubCmdStart:
 		p.Next()
 		return ast.NewScopeValueNodeList1(expectSubCmd(ctx, p))
+	case lexer.TokenLBracket:
+		p.Next()
+		return ast.NewScopeValueNodeList1(expectList(ctx, p))
 	case lexer.TokenDollar:
 		t := p.Next()
 		panic(fmt.Sprintf("%d:%d: $ must be followed by '{' or '('", t.Line(), t.Column()))
@@ -605,6 +1052,78 @@ func expectAssignmentValue(ctx *parseContext, p *parser.Parser) *ast.ScopeValueN
 	}
 }
 
+// expectIfCondition expects either a test string, or a comparison: value ( '==' | '!=' ) value
+// Expects lexer.fn == LexIf BEFORE calling.
+//
+func expectIfCondition(ctx *parseContext, p *parser.Parser) ast.IfCondition {
+	if tryPeekType(p, lexer.TokenBracketStringStart) ||
+		tryPeekType(p, lexer.TokenDBracketStringStart) ||
+		tryPeekType(p, lexer.TokenParenStringStart) ||
+		tryPeekType(p, lexer.TokenDParenStringStart) {
+		return &ast.IfTest{Test: expectTestString(ctx, p)}
+	}
+	left := expectIfValue(ctx, p)
+	ctx.setLexFn(lexer.LexIfCompareOp)
+	var negate bool
+	switch {
+	case tryPeekType(p, lexer.TokenEqualsEquals):
+		p.Next()
+	case tryPeekType(p, lexer.TokenBangEquals):
+		p.Next()
+		negate = true
+	case p.CanPeek(1) && len(p.Peek(1).Value()) > 0:
+		panic(parseError(p, "expecting TokenEqualsEquals ('==') or TokenBangEquals ('!=') - values containing whitespace must be quoted"))
+	default:
+		panic(parseError(p, "expecting TokenEqualsEquals ('==') or TokenBangEquals ('!=')"))
+	}
+	ctx.setLexFn(lexer.LexIfValue)
+	right := expectIfValue(ctx, p)
+	ctx.setLexFn(lexer.LexIfEnd)
+	if tryPeekType(p, lexer.TokenRunes) {
+		panic(parseError(p, "unexpected text after IF comparison - values containing whitespace must be quoted"))
+	}
+	return &ast.IfCompare{Left: left, Right: right, Negate: negate}
+}
+
+// expectIfValue expects a value of an IF comparison, up to lexer.TokenIfValueEnd.
+// Expects lexer.fn == LexIfValue BEFORE calling.
+//
+func expectIfValue(ctx *parseContext, p *parser.Parser) *ast.ScopeValueNodeList {
+	values := make([]ast.ScopeValueNode, 0)
+	for p.CanPeek(1) {
+		switch p.PeekType(1) {
+		// Character run
+		//
+		case lexer.TokenRunes:
+			values = append(values, &ast.ScopeValueRunes{Value: p.Next().Value()})
+		// Quoted strings
+		//
+		case lexer.TokenDQStringStart:
+			p.Next()
+			values = append(values, expectDQString(ctx, p))
+		case lexer.TokenSQStringStart:
+			p.Next()
+			values = append(values, expectSQString(ctx, p))
+		// Var Ref
+		//
+		case lexer.TokenVarRefStart:
+			p.Next()
+			values = append(values, expectVarRef(ctx, p))
+		// Substitution
+		//
+		case lexer.TokenSubCmdStart:
+			p.Next()
+			values = append(values, expectSubCmd(ctx, p))
+		// End of value
+		//
+		default:
+			expectTokenType(p, lexer.TokenIfValueEnd, "expecting end of IF value")
+			return ast.NewScopeValueNodeList(values)
+		}
+	}
+	panic(parseError(p, "expecting end of IF value"))
+}
+
 // expectAssertMessage
 // Expects lexer.fn == LexAssertMessage BEFORE calling.
 //
@@ -623,16 +1142,36 @@ func expectAssertMessage(ctx *parseContext, p *parser.Parser) *ast.ScopeValueNod
 	}
 }
 
+// isListIndex returns true if the value is a valid list index: '@' | [0-9]+
+//
+func isListIndex(value string) bool {
+	if value == "@" {
+		return true
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return len(value) > 0
+}
+
 // expectVarRef
 //
-func expectVarRef(ctx *parseContext, p *parser.Parser) *ast.ScopeValueVar {
+func expectVarRef(ctx *parseContext, p *parser.Parser) ast.ScopeValueNode {
 	ctx.setLexFn(lexer.LexVarRef)
 	// Dollar
 	//
-	expectTokenType(p, lexer.TokenDollar, "expecting TokenDollar ('$')")
+	t := expectTokenType(p, lexer.TokenDollar, "expecting TokenDollar ('$')")
 	// Open Brace
 	//
 	expectTokenType(p, lexer.TokenLBrace, "expecting TokenLBrace ('{')")
+	// Length '#'
+	//
+	length := tryPeekType(p, lexer.TokenVarLength)
+	if length {
+		p.Next()
+	}
 	// Value
 	//
 	name := expectTokenType(p, lexer.TokenRunes, "expecting TokenRunes").Value()
@@ -641,23 +1180,108 @@ func expectVarRef(ctx *parseContext, p *parser.Parser) *ast.ScopeValueVar {
 	if strings.HasPrefix(name, ".") {
 		name = strings.ToUpper(name)
 	}
+	ref := &ast.ScopeValueVar{
+		Name:    name,
+		Runfile: config.CurrentRunfile,
+		Line:    t.Line(),
+	}
+	// List Index
+	//
+	if tryPeekType(p, lexer.TokenVarIndex) {
+		if !isListIndex(p.Peek(1).Value()) {
+			panic(parseError(p, "expecting list index ('@' or number)"))
+		}
+		ref.Index = p.Next().Value()
+		expectTokenType(p, lexer.TokenRBracket, "expecting TokenRBracket (']')")
+	}
+	// Operator
+	//
+	if tryPeekType(p, lexer.TokenVarOp) {
+		if length {
+			panic(parseError(p, "expecting TokenRBrace ('}')"))
+		}
+		op := p.Next().Value()
+		word := expectVarRefOperand(ctx, p)
+		switch op {
+		case "-", ":-", "=", ":=":
+			return &ast.ScopeValueVarDefault{Var: ref, Op: op, Value: word}
+		case "?", ":?":
+			return &ast.ScopeValueVarError{Var: ref, Op: op, Message: word}
+		case "+", ":+":
+			return &ast.ScopeValueVarAlt{Var: ref, Op: op, Value: word}
+		case ":":
+			return &ast.ScopeValueVarSubstr{Var: ref, Range: word}
+		case "#", "##", "%", "%%":
+			return &ast.ScopeValueVarTrim{Var: ref, Op: op, Pattern: word}
+		default: // "/", "//"
+			var replacement ast.ScopeValueNode = ast.NewScopeValueNodeList([]ast.ScopeValueNode{})
+			if tryPeekType(p, lexer.TokenVarOpSep) {
+				p.Next()
+				replacement = expectVarRefOperand(ctx, p)
+			}
+			return &ast.ScopeValueVarReplace{Var: ref, Op: op, Pattern: word, Replacement: replacement}
+		}
+	}
 	// Close Brace
 	//
 	expectTokenType(p, lexer.TokenRBrace, "expecting TokenRBrace ('}')")
 
-	return &ast.ScopeValueVar{Name: name}
+	if length {
+		return &ast.ScopeValueVarLength{Var: ref}
+	}
+	return ref
+}
+
+// expectVarRefOperand expects the operand of a parameter expansion.
+// Consumes the closing brace, unless the operand is followed by a '/' separator.
+//
+func expectVarRefOperand(ctx *parseContext, p *parser.Parser) *ast.ScopeValueNodeList {
+	values := make([]ast.ScopeValueNode, 0)
+
+	for p.CanPeek(1) {
+		switch p.PeekType(1) {
+		// Character run
+		//
+		case lexer.TokenRunes:
+			values = append(values, &ast.ScopeValueRunes{Value: p.Next().Value()})
+		// Escape char
+		//
+		case lexer.TokenEscapeSequence:
+			values = append(values, &ast.ScopeValueEsc{Seq: p.Next().Value()})
+		// Var Ref
+		//
+		case lexer.TokenVarRefStart:
+			p.Next()
+			values = append(values, expectVarRef(ctx, p))
+		// Separator
+		//
+		case lexer.TokenVarOpSep:
+			return ast.NewScopeValueNodeList(values)
+		// Close Brace
+		//
+		default:
+			expectTokenType(p, lexer.TokenRBrace, "expecting TokenRBrace ('}')")
+			return ast.NewScopeValueNodeList(values)
+		}
+	}
+	panic(parseError(p, "expecting TokenRBrace ('}')"))
 }
 
 // expectSubCmd
 //
-func expectSubCmd(ctx *parseContext, p *parser.Parser) *ast.ScopeValueShell {
+func expectSubCmd(ctx *parseContext, p *parser.Parser) ast.ScopeValueNode {
 	ctx.setLexFn(lexer.LexSubCmd)
 	// Dollar
 	//
-	expectTokenType(p, lexer.TokenDollar, "expecting TokenDollar ('$')")
+	t := expectTokenType(p, lexer.TokenDollar, "expecting TokenDollar ('$')")
 	// Open Paren
 	//
 	expectTokenType(p, lexer.TokenLParen, "expecting TokenLParen ('(')")
+	// Function call
+	//
+	if tryPeekType(p, lexer.TokenFnName) {
+		return expectFnCall(ctx, p, t)
+	}
 
 	// Values
 	//
@@ -677,7 +1301,11 @@ func expectSubCmd(ctx *parseContext, p *parser.Parser) *ast.ScopeValueShell {
 		//
 		default:
 			expectTokenType(p, lexer.TokenRParen, "expecting TokenRParen (')')")
-			return &ast.ScopeValueShell{Cmd: ast.NewScopeValueNodeList(values)}
+			return &ast.ScopeValueShell{
+				Runfile: config.CurrentRunfile,
+				Line:    t.Line(),
+				Cmd:     ast.NewScopeValueNodeList(values),
+			}
 		}
 	}
 	panic(parseError(p, "expecting tokenRParen (')')"))
@@ -742,6 +1370,138 @@ func expectDQString(ctx *parseContext, p *parser.Parser) *ast.ScopeValueNodeList
 	panic(parseError(p, "expecting TokenDoubleQuote ('\"')"))
 }
 
+// expectFnCall expects the name and args of a function call, along with the closing paren.
+//
+func expectFnCall(ctx *parseContext, p *parser.Parser, start token.Token) *ast.ScopeValueFn {
+	nameToken := expectTokenType(p, lexer.TokenFnName, "expecting TokenFnName")
+	name := strings.ToLower(nameToken.Value())
+	args := make([]ast.ScopeValueNode, 0)
+	values := make([]ast.ScopeValueNode, 0)
+	inArg := false
+	// endArg adds the current arg, if any
+	//
+	endArg := func() {
+		if inArg {
+			args = append(args, ast.NewScopeValueNodeList(values))
+			values = make([]ast.ScopeValueNode, 0)
+			inArg = false
+		}
+	}
+	for p.CanPeek(1) {
+		switch p.PeekType(1) {
+		// Arg separator
+		//
+		case lexer.TokenFnArgSep:
+			p.Next()
+			endArg()
+			continue
+		// Character run
+		//
+		case lexer.TokenRunes:
+			values = append(values, &ast.ScopeValueRunes{Value: p.Next().Value()})
+		// Escape char
+		//
+		case lexer.TokenEscapeSequence:
+			values = append(values, &ast.ScopeValueEsc{Seq: p.Next().Value()})
+		// Quoted strings
+		//
+		case lexer.TokenDQStringStart:
+			p.Next()
+			values = append(values, expectDQString(ctx, p))
+		case lexer.TokenSQStringStart:
+			p.Next()
+			values = append(values, expectSQString(ctx, p))
+		// Var Ref
+		//
+		case lexer.TokenVarRefStart:
+			p.Next()
+			values = append(values, expectVarRef(ctx, p))
+		// Nested substitution
+		//
+		case lexer.TokenSubCmdStart:
+			p.Next()
+			values = append(values, expectSubCmd(ctx, p))
+		// Close Paren
+		//
+		default:
+			expectTokenType(p, lexer.TokenRParen, "expecting TokenRParen (')')")
+			endArg()
+			if err := ast.CheckFn(name, len(args)); err != nil {
+				panic(tokenError(nameToken, err.Error()))
+			}
+			return &ast.ScopeValueFn{
+				Runfile: config.CurrentRunfile,
+				Line:    start.Line(),
+				Name:    name,
+				Args:    args,
+			}
+		}
+		inArg = true
+	}
+	panic(parseError(p, "expecting TokenRParen (')')"))
+}
+
+// expectList expects the items of a list literal, along with the closing bracket.
+//
+func expectList(ctx *parseContext, p *parser.Parser) *ast.ScopeValueList {
+	items := make([]ast.ScopeValueNode, 0)
+	values := make([]ast.ScopeValueNode, 0)
+	inItem := false
+	// endItem adds the current item, if any
+	//
+	endItem := func() {
+		if inItem {
+			items = append(items, ast.NewScopeValueNodeList(values))
+			values = make([]ast.ScopeValueNode, 0)
+			inItem = false
+		}
+	}
+	for p.CanPeek(1) {
+		switch p.PeekType(1) {
+		// Item separator
+		//
+		case lexer.TokenListItemSep:
+			p.Next()
+			endItem()
+			continue
+		// Character run
+		//
+		case lexer.TokenRunes:
+			values = append(values, &ast.ScopeValueRunes{Value: p.Next().Value()})
+		// Escape char
+		//
+		case lexer.TokenEscapeSequence:
+			values = append(values, &ast.ScopeValueEsc{Seq: p.Next().Value()})
+		// Quoted strings
+		//
+		case lexer.TokenDQStringStart:
+			p.Next()
+			values = append(values, expectDQString(ctx, p))
+		case lexer.TokenSQStringStart:
+			p.Next()
+			values = append(values, expectSQString(ctx, p))
+		// Var Ref
+		//
+		case lexer.TokenVarRefStart:
+			p.Next()
+			values = append(values, expectVarRef(ctx, p))
+		// Substitution
+		//
+		case lexer.TokenSubCmdStart:
+			p.Next()
+			values = append(values, expectSubCmd(ctx, p))
+		// Close Bracket
+		//
+		default:
+			expectTokenType(p, lexer.TokenRBracket, "expecting TokenRBracket (']')")
+			endItem()
+			return &ast.ScopeValueList{Values: items}
+		}
+		inItem = true
+	}
+	panic(parseError(p, "expecting TokenRBracket (']')"))
+}
+
 // expectTestString - Expects lexer.fn == lexTestString BEFORE calling.
 //
 func expectTestString(_ *parseContext, p *parser.Parser) ast.ScopeValueNode {
@@ -798,6 +1558,14 @@ func expectTestString(_ *parseContext, p *parser.Parser) ast.ScopeValueNode {
 // tryMatchCmdHeaderWithShell matches [ [ 'CMD' ] [ '@' ] DASH_ID ( '(' ID ')' )? ( ':' | '{' ) ]
 //
 func tryMatchCmdHeaderWithShell(ctx *parseContext, p *parser.Parser) (config.CmdFlags, string, string, int, bool) {
+	flags := config.CmdFlags(0)
+	// Override
+	//
+	override := tryPeekType(p, lexer.TokenOverride)
+	if override {
+		expectTokenType(p, lexer.TokenOverride, "expecting TokenOverride")
+		flags |= config.FlagOverride
+	}
 	expectCommand := tryPeekType(p, lexer.TokenCommand)
 	if expectCommand {
 		expectTokenType(p, lexer.TokenCommand, "expecting TokenCommand")
@@ -811,6 +1579,9 @@ func tryMatchCmdHeaderWithShell(ctx *parseContext, p *parser.Parser) (config.Cmd
 				tryPeekTypes(p, lexer.TokenID, lexer.TokenLBrace)
 	}
 	if !expectCommand {
+		if override {
+			panic(parseError(p, "expecting command after OVERRIDE"))
+		}
 		return 0, "", "", -1, false
 	}
 	// Name + Line
@@ -828,7 +1599,6 @@ func tryMatchCmdHeaderWithShell(ctx *parseContext, p *parser.Parser) (config.Cmd
 	}
 	name := t.Value()
 	line := t.Line()
-	flags := config.CmdFlags(0)
 	// Hidden / Private
 	//
 	if strings.HasPrefix(name, ".") {
@@ -0,0 +1,199 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// tryParse parses the runfile source, returning the parse error, if any.
+//
+func tryParse(src string) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	ParseBytes([]byte(src))
+	return nil
+}
+
+// parseTest is a runfile source, along with the expected error message, if any.
+//
+type parseTest struct {
+	name    string
+	src     string
+	wantErr string // Substring of the expected error; Empty = expect success
+}
+
+// runParseTests checks each source parses, or fails to parse, as expected.
+//
+func runParseTests(t *testing.T, tests []parseTest) {
+	t.Helper()
+	for _, test := range tests {
+		err := tryParse(test.src)
+		switch {
+		case len(test.wantErr) == 0 && err != nil:
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+		case len(test.wantErr) > 0 && err == nil:
+			t.Errorf("%s: expected error containing %q", test.name, test.wantErr)
+		case len(test.wantErr) > 0 && !strings.Contains(err.Error(), test.wantErr):
+			t.Errorf("%s: expected error containing %q, got: %v", test.name, test.wantErr, err)
+		}
+	}
+}
+
+func TestParseHooks(t *testing.T) {
+	runParseTests(t, []parseTest{
+		{"before.all", "BEFORE.ALL setup\nsetup:\n  echo setup\n", ""},
+		{"after.all with patterns", "AFTER.ALL report build* db/*\nreport:\n  echo report\n", ""},
+		{"before.all super", "BEFORE.ALL ^\n", "BEFORE.ALL cannot invoke the overridden command (^)"},
+		{"after.all super", "AFTER.ALL ^ build*\n", "AFTER.ALL cannot invoke the overridden command (^)"},
+		{"before.all missing name", "BEFORE.ALL\n", "1.11: expecting command name"},
+		{"after.all invalid name", "AFTER.ALL 1build\n", "1.11: expecting command name"},
+		{"run missing name", "##\n# RUN\nbuild:\n  echo build\n", "2.6: expecting command name"},
+		{"run super", "##\n# RUN ^\nbuild:\n  echo build\n", ""},
+	})
+}
+
+// TestParseKeywordNames checks that names used by runfiles before OVERRIDE, OPTIONS, OPTION, IF, ELSE and END
+// were introduced still parse as command names and variable names.
+//
+func TestParseKeywordNames(t *testing.T) {
+	var tests []parseTest
+	for _, name := range []string{"override", "options", "option", "if", "else", "end", "OVERRIDE", "Options", "End"} {
+		tests = append(tests,
+			parseTest{name + ":", name + ":\n  echo cmd\n", ""},
+			parseTest{name + " :", name + " :\n  echo cmd\n", ""},
+			parseTest{name + " (sh):", name + " (sh):\n  echo cmd\n", ""},
+			parseTest{"doc block " + name + ":", "##\n# Description\n" + name + ":\n  echo cmd\n", ""},
+			parseTest{name + " := value", name + " := value\n", ""},
+			parseTest{name + " ?= value", name + " ?= value\n", ""},
+			parseTest{name + " += value", name + " += value\n", ""},
+			parseTest{name + " != echo value", name + " != echo value\n", ""},
+			parseTest{name + " ::= value", name + " ::= value\n", ""},
+			parseTest{name + " := value, no newline", name + " := value", ""},
+		)
+	}
+	tests = append(tests,
+		parseTest{"OVERRIDE keyword", "OVERRIDE build:\n  echo build\n", ""},
+		parseTest{"override keyword", "override build:\n  echo build\n", ""},
+		parseTest{"OVERRIDE missing command", "OVERRIDE\n", "expecting command after OVERRIDE"},
+		parseTest{"OPTIONS keyword", "##\n# OPTION ENV -e,--env <name> Target environment\nOPTIONS common\n", ""},
+		parseTest{"OPTIONS without doc block", "OPTIONS common\n", "OPTIONS must follow a doc block"},
+		parseTest{"OPTION keyword", "OPTION VERBOSE -v,--verbose Show verbose output\n", ""},
+		parseTest{"IF keyword", "A := 1\nIF ${A} == 1\nB := 2\nELSE\nB := 3\nEND\n", ""},
+		parseTest{"if keyword", "if \"${A}\" != 1\nend\n", ""},
+		parseTest{"IF test keyword", "IF [ -n \"${HOME}\" ]\nEND\n", ""},
+		parseTest{"IF without END", "IF ${A} == 1\n", "IF without matching END"},
+		parseTest{"END without IF", "END\n", "END without matching IF"},
+		parseTest{"ELSE without IF", "ELSE\n", "ELSE without matching IF"},
+	)
+	runParseTests(t, tests)
+}
+
+func TestParseFunctions(t *testing.T) {
+	runParseTests(t, []parseTest{
+		{"function", "A := $(fn:upper abc)\n", ""},
+		{"nested function", "A := $(fn:upper $(fn:basename ${B} .txt))\n", ""},
+		{"function wrong arg count", "A := $(fn:upper)\n", "fn:upper: expecting 1 arg(s), got 0"},
+		{"unknown function is a shell substitution", "A := $(fn:nope abc)\n", ""},
+		{"shell command starting with fn:", "A := $(fn:upper-case abc | tr a b)\n", ""},
+	})
+}
+
+func TestParseLists(t *testing.T) {
+	runParseTests(t, []parseTest{
+		{"list", "A := [ a \"b c\" ${B} $(echo d) ]\n", ""},
+		{"list, no newline", "A := [ a b ]", ""},
+		{"no space is a string", "A := [abc]\n", ""},
+		{"append list", "A += [ a b ]\n", ""},
+		{"export list", "EXPORT A := [ a b ]\n", ""},
+		{"run.each list", "##\n# RUN.EACH build [ a b ]\nall:\n  echo\n", ""},
+		{"unterminated list", "A := [ a b\n", "TokenRBracket"},
+	})
+}
+
+func TestParseListIndexes(t *testing.T) {
+	runParseTests(t, []parseTest{
+		{"index", "A := ${B[0]}\n", ""},
+		{"index, multi-digit", "A := ${B[10]}\n", ""},
+		{"all items", "A := ${B[@]}\n", ""},
+		{"length", "A := ${#B[@]}\n", ""},
+		{"index with operator", "A := ${B[1]:-x}\n", ""},
+		{"index in string", "A := \"${B[1]}\"\n", ""},
+		{"invalid index", "A := ${B[x]}\n", "1.10: expecting list index ('@' or number)"},
+		{"negative index", "A := ${B[-1]}\n", "1.10: expecting list index ('@' or number)"},
+		{"empty index", "A := ${B[]}\n", "1.10: expecting list index ('@' or number)"},
+		{"unterminated index", "A := ${B[0}\n", "1.11: expecting TokenRBracket (']')"},
+	})
+}
+
+func TestParseIf(t *testing.T) {
+	runParseTests(t, []parseTest{
+		{"compare", "IF ${A} == dev\nEND\n", ""},
+		{"compound left", "IF ${A}-x == dev-x\nEND\n", ""},
+		{"compound right", "IF dev-x != ${A}-x\nEND\n", ""},
+		{"compound substitution", "IF x$(echo y)${A} == \"xy\"'z'\nEND\n", ""},
+		{"no space around operator", "IF ${A}==dev\nEND\n", ""},
+		{"quoted", "IF \"${A} b\" == 'a b'\nEND\n", ""},
+		{"empty right", "IF ${A} ==\nEND\n", ""},
+		{"trailing space", "IF ${A} == dev \nEND\n", ""},
+		{"test", "IF [ -n \"${A}\" ]\nEND\n", ""},
+		{"unquoted whitespace", "IF ${A} == dev x\nEND\n", "1.16: unexpected text after IF comparison - values containing whitespace must be quoted"},
+		{"unquoted whitespace, left", "IF a b == c\nEND\n", "1.6: expecting TokenEqualsEquals ('==') or TokenBangEquals ('!=') - values containing whitespace must be quoted"},
+		{"missing operator", "IF ${A}\nEND\n", "1.8: expecting TokenEqualsEquals ('==') or TokenBangEquals ('!=')"},
+	})
+}
+
+func TestParseForeach(t *testing.T) {
+	runParseTests(t, []parseTest{
+		{"foreach", "##\n# FOREACH GOOS in linux darwin\ntest:\n  echo\n", ""},
+		{"foreach values", "##\n# FOREACH V in \"a b\" ${A} ${L[@]} $(echo c)\ntest:\n  echo\n", ""},
+		{"foreach matrix", "##\n# FOREACH A in 1 2\n# FOREACH B in x y\ntest:\n  echo\n", ""},
+		{"foreach parallel", "##\n# FOREACH A in 1 2\n# FOREACH.PARALLEL\ntest:\n  echo\n", ""},
+		{"foreach missing in", "##\n# GROUP g\n# FOREACH A 1 2\ntest:\n  echo\n", "3.13: expecting TokenIn ('in')"},
+		{"foreach missing name", "##\n# GROUP g\n# FOREACH in 1 2\ntest:\n  echo\n", "3.14: expecting TokenIn ('in')"},
+		{"foreach invalid name", "##\n# GROUP g\n# FOREACH 1A in 1 2\ntest:\n  echo\n", "3.11: expecting TokenID"},
+		{"foreach malformed in description", "##\n# FOREACH A 1 2\ntest:\n  echo\n", ""},
+	})
+}
+
+func TestParseAssignmentOperators(t *testing.T) {
+	runParseTests(t, []parseTest{
+		{"append", "A += b\n", ""},
+		{"append list", "A += [ b c ]\n", ""},
+		{"shell", "A != ls *.go | sort\n", ""},
+		{"shell with var", "A != echo ${B} $(fn:upper c)\n", ""},
+		{"readonly", "A ::= b\n", ""},
+		{"export append", "EXPORT A += b\n", ""},
+		{"export shell", "EXPORT A != echo b\n", ""},
+		{"export readonly", "EXPORT A ::= b\n", ""},
+		{"doc block append", "##\n# EXPORT A += b\nbuild:\n  echo\n", ""},
+		{"doc block shell", "##\n# EXPORT A != echo b\nbuild:\n  echo\n", ""},
+		{"doc block readonly", "##\n# EXPORT A ::= b\nbuild:\n  echo\n", ""},
+	})
+}
+
+// TestParseDocBlockDescKeywords checks that description lines starting with doc block keywords
+// introduced after doc blocks could already contain them remain description text.
+//
+func TestParseDocBlockDescKeywords(t *testing.T) {
+	var tests []parseTest
+	for _, line := range []string{
+		"Group related targets.",
+		"Default behaviour is to build everything.",
+		"Alias for the deploy target.",
+		"Run.super target, first.",
+		"Foreach target, run the linter.",
+		"Foreach.parallel runs are fast.",
+		"Options are documented below.",
+		"Run.each target, once.",
+		"DEFAULT is everything.",
+		"FOREACH of the targets.",
+		"FOREACH.PARALLEL is fast.",
+	} {
+		tests = append(tests, parseTest{line, "##\n# Builds\n# " + line + "\nbuild:\n  echo\n", ""})
+	}
+	runParseTests(t, tests)
+}
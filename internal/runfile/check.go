@@ -0,0 +1,92 @@
+package runfile
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/tekwizely/run/internal/config"
+)
+
+// UndefinedVar captures a reference to an undefined variable.
+//
+type UndefinedVar struct {
+	Name    string
+	Runfile string
+	Line    int
+}
+
+// undefinedVars tracks references to undefined variables, keyed by runfile:line:name to avoid dupes.
+//
+var undefinedVars = map[string]*UndefinedVar{}
+
+// AddUndefinedVar records a reference to an undefined variable.
+//
+func AddUndefinedVar(name string, runfile string, line int) {
+	key := fmt.Sprintf("%s:%d:%s", runfile, line, name)
+	if _, ok := undefinedVars[key]; !ok {
+		undefinedVars[key] = &UndefinedVar{Name: name, Runfile: runfile, Line: line}
+	}
+}
+
+// RunCheck evaluates every value in the runfile, reporting references to undefined variables.
+// Check mode is only enabled once the builtin has been resolved, so that a runfile command named 'check' runs normally.
+// Values evaluated while processing the runfile (i.e. IF conditions) are evaluated normally.
+//
+func RunCheck(rf *Runfile) int {
+	// Runfile not found - Error already reported
+	//
+	if rf == nil {
+		return 2
+	}
+	config.CheckMode = true
+	evaluateScope(rf.Scope)
+	for _, provider := range rf.Cmds {
+		cmd := provider.GetCmd(rf)
+		evaluateScope(cmd.Scope)
+		for _, opt := range cmd.AllOpts() {
+			if opt.HasDefault {
+				opt.Default.Get()
+			}
+		}
+		for _, runs := range [][]*RunCmdRun{cmd.Config.EnvRuns, cmd.Config.BeforeRuns, cmd.Config.AfterRuns} {
+			for _, run := range runs {
+				run.Invocations()
+			}
+		}
+		for _, foreach := range cmd.Config.Foreach {
+			foreach.Values.Items()
+		}
+	}
+	if len(undefinedVars) == 0 {
+		return 0
+	}
+	vars := make([]*UndefinedVar, 0, len(undefinedVars))
+	for _, v := range undefinedVars {
+		vars = append(vars, v)
+	}
+	sort.Slice(vars, func(i, j int) bool {
+		if vars[i].Runfile != vars[j].Runfile {
+			return vars[i].Runfile < vars[j].Runfile
+		}
+		if vars[i].Line != vars[j].Line {
+			return vars[i].Line < vars[j].Line
+		}
+		return vars[i].Name < vars[j].Name
+	})
+	for _, v := range vars {
+		fmt.Fprintf(config.ErrOut, "%s:%d: variable not defined: %s\n", v.Runfile, v.Line, v.Name)
+	}
+	return 1
+}
+
+// evaluateScope forces evaluation of the variables and assertions within the scope.
+//
+func evaluateScope(s *Scope) {
+	for _, name := range s.VarNames() {
+		s.GetVar(name)
+	}
+	for _, assert := range s.Asserts {
+		assert.Test.Get()
+		assert.Message.Get()
+	}
+}
@@ -114,7 +114,10 @@ func (a *stringOpt) String() string {
 	if a.set {
 		return *a.value
 	}
-	return a.runfileOpt.Default
+	if a.runfileOpt.HasDefault {
+		return a.runfileOpt.Default.Get()
+	}
+	return ""
 }
 
 // boolOpt
@@ -150,9 +153,16 @@ func evaluateCmdOpts(cmd *RunCmd, args []string) ([]string, int) {
 	// If no options defined, pass all args through to command script
 	// NOTE: For MainMode we still define options, mainly for --help
 	//
-	if len(cmd.Config.Opts) == 0 && !config.MainMode {
+	opts := cmd.AllOpts()
+	if len(opts) == 0 && !config.MainMode {
 		return args, 0
 	}
+	// If only global options apply, extract them, passing all other args through to command script
+	//
+	var passArgs []string
+	if len(cmd.Config.Opts) == 0 && !config.MainMode {
+		args, passArgs = splitGlobalOptArgs(opts, args)
+	}
 	flags := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
 	var (
 		stringValues = make(map[string]*stringOpt)
@@ -163,7 +173,7 @@ func evaluateCmdOpts(cmd *RunCmd, args []string) ([]string, int) {
 	help := false
 	hasHelpShort := false
 	hasHelpLong := false
-	for _, opt := range cmd.Config.Opts {
+	for _, opt := range opts {
 		// If explicitly added, then cannot be overridden
 		//
 		// 'h' != 'H'
@@ -230,7 +240,7 @@ func evaluateCmdOpts(cmd *RunCmd, args []string) ([]string, int) {
 	// TODO Maybe make args property instead of stashing in vars?
 	//
 	var missingRequired []*RunCmdOpt
-	for _, opt := range cmd.Config.Opts {
+	for _, opt := range opts {
 		// String or Bool?
 		//
 		if len(opt.Example) > 0 {
@@ -239,7 +249,7 @@ func evaluateCmdOpts(cmd *RunCmd, args []string) ([]string, int) {
 				missingRequired = append(missingRequired, value.runfileOpt)
 				continue
 			}
-			cmd.Scope.Vars[opt.Name] = value.String()
+			cmd.Scope.Vars[opt.Name] = NewValue(value.String())
 			cmd.Scope.ExportVar(opt.Name)
 		} else {
 			value := boolValues[opt.Name]
@@ -247,7 +257,7 @@ func evaluateCmdOpts(cmd *RunCmd, args []string) ([]string, int) {
 				missingRequired = append(missingRequired, value.runfileOpt)
 				continue
 			}
-			cmd.Scope.Vars[opt.Name] = value.String()
+			cmd.Scope.Vars[opt.Name] = NewValue(value.String())
 			cmd.Scope.ExportVar(opt.Name)
 		}
 	}
@@ -266,13 +276,58 @@ func evaluateCmdOpts(cmd *RunCmd, args []string) ([]string, int) {
 			b.WriteString("  ")
 			b.WriteString(getCommonOptString(opt))
 			b.WriteString("\n        ")
-			b.WriteString(opt.Desc)
+			b.WriteString(opt.Desc.Get())
 		}
 		_, _ = fmt.Fprintf(config.ErrOut, "%s: ERROR: Missing required %s:\n%s\n", cmd.Name, option, b.String())
 		// ~= log.Fatal
 		return nil, 1
 	}
-	return flags.Args(), 0
+	return append(flags.Args(), passArgs...), 0
+}
+
+// splitGlobalOptArgs splits args into those matching the global options (along with their values) and all others.
+// Args following '--' are never matched.
+// Returns (globalArgs, otherArgs), each in their original order.
+//
+func splitGlobalOptArgs(opts []*RunCmdOpt, args []string) ([]string, []string) {
+	var globalArgs, otherArgs []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			otherArgs = append(otherArgs, args[i:]...)
+			break
+		}
+		// Accepts -name, --name, -name=value, --name=value
+		//
+		name := strings.TrimPrefix(strings.TrimPrefix(arg, "-"), "-")
+		if name == arg || len(name) == 0 {
+			otherArgs = append(otherArgs, arg)
+			continue
+		}
+		hasValue := false
+		if eq := strings.IndexByte(name, '='); eq >= 0 {
+			name, hasValue = name[:eq], true
+		}
+		var match *RunCmdOpt
+		for _, opt := range opts {
+			if (opt.Short != 0 && name == string([]rune{opt.Short})) || (len(opt.Long) > 0 && strings.EqualFold(name, opt.Long)) {
+				match = opt
+				break
+			}
+		}
+		if match == nil {
+			otherArgs = append(otherArgs, arg)
+			continue
+		}
+		globalArgs = append(globalArgs, arg)
+		// String options consume the following arg as their value
+		//
+		if len(match.Example) > 0 && !hasValue && i+1 < len(args) {
+			i++
+			globalArgs = append(globalArgs, args[i])
+		}
+	}
+	return globalArgs, otherArgs
 }
 
 func getCommonOptString(opt *RunCmdOpt) string {
@@ -314,13 +369,18 @@ func ShowCmdHelp(cmd *RunCmd) {
 	fmt.Fprintf(config.ErrOut, "%s%s:\n", cmd.Name, shell)
 	// Desc
 	//
-	if len(cmd.Config.Desc) > 0 {
-		for _, desc := range cmd.Config.Desc {
+	if descs := cmd.Config.Desc.Items(); len(descs) > 0 {
+		for _, desc := range descs {
 			fmt.Fprintf(config.ErrOut, "  %s\n", desc)
 		}
 		// } else {
 		// 	fmt.Fprintf(errOut, "%s:\n", cmd.name)
 	}
+	// Aliases
+	//
+	if len(cmd.Config.Aliases) > 0 {
+		fmt.Fprintf(config.ErrOut, "Aliases:\n  %s\n", strings.Join(cmd.Config.Aliases, ", "))
+	}
 	showCmdUsage(cmd)
 }
 
@@ -339,7 +399,7 @@ func showCmdUsage(cmd *RunCmd) {
 	}
 	// Usages
 	//
-	for i, usage := range cmd.Config.Usages {
+	for i, usage := range cmd.Config.Usages.Items() {
 		or := "or"
 		if i == 0 {
 			fmt.Fprintf(config.ErrOut, "Usage:\n")
@@ -354,7 +414,7 @@ func showCmdUsage(cmd *RunCmd) {
 	}
 	hasHelpShort := false
 	hasHelpLong := false
-	for _, opt := range cmd.Config.Opts {
+	for _, opt := range cmd.AllOpts() {
 		if opt.Short == 'h' {
 			hasHelpShort = true
 		}
@@ -364,7 +424,7 @@ func showCmdUsage(cmd *RunCmd) {
 	}
 	// Options
 	//
-	if len(cmd.Config.Opts) > 0 {
+	if len(cmd.Config.Opts) > 0 || len(cmd.Config.GlobalOpts) > 0 {
 		fmt.Fprintln(config.ErrOut, "Options:")
 		if !hasHelpShort || !hasHelpLong {
 			switch {
@@ -378,7 +438,20 @@ func showCmdUsage(cmd *RunCmd) {
 			fmt.Fprintln(config.ErrOut, "        Show full help screen")
 		}
 	}
-	for _, opt := range cmd.Config.Opts {
+	showCmdOpts(cmd.Config.Opts)
+	// Global Options
+	//
+	if len(cmd.Config.GlobalOpts) > 0 {
+		fmt.Fprintln(config.ErrOut, "Global Options:")
+		showCmdOpts(cmd.Config.GlobalOpts)
+	}
+}
+
+// showCmdOpts shows the list of options, along with their descriptions.
+//
+//goland:noinspection GoUnhandledErrorResult // fmt.*
+func showCmdOpts(opts []*RunCmdOpt) {
+	for _, opt := range opts {
 		b := &strings.Builder{}
 		b.WriteString("  ")
 		b.WriteString(getCommonOptString(opt))
@@ -388,35 +461,98 @@ func showCmdUsage(cmd *RunCmd) {
 		}
 		if opt.HasDefault {
 			b.WriteRune(' ')
-			b.WriteString(fmt.Sprintf("(default: %s)", opt.Default))
+			b.WriteString(fmt.Sprintf("(default: %s)", opt.Default.Get()))
 		}
-		if opt.Desc != "" {
+		if desc := opt.Desc.Get(); desc != "" {
 			if opt.Short != 0 && opt.Long == "" && opt.Example == "" && !opt.Required && !opt.HasDefault {
 				b.WriteString("    ")
 			} else {
 				b.WriteString("\n        ") // Leading \n
 			}
-			b.WriteString(opt.Desc)
+			b.WriteString(desc)
 		}
 		fmt.Fprintln(config.ErrOut, b.String())
 	}
 }
 
+// listName returns the name of the command as shown in command lists, including any aliases.
+//
+func listName(cmd *config.Command) string {
+	if len(cmd.Aliases) == 0 {
+		return cmd.Name
+	}
+	return fmt.Sprintf("%s (%s)", cmd.Name, strings.Join(cmd.Aliases, ", "))
+}
+
 // ListCommands prints the list of commands read from the runfile
+// If any runfile commands define a group, commands are listed in sections:
+// Ungrouped commands first, then each group in registration order, then builtins.
 //
 func ListCommands() {
-	_, _ = fmt.Fprintln(config.ErrOut, "Commands:")
 	padLen := 0
+	grouped := false
 	for _, cmd := range config.CommandList {
-		if !cmd.Flags.Private() && !cmd.Flags.Hidden() && len(cmd.Name) > padLen {
-			padLen = len(cmd.Name)
+		if !cmd.Flags.Private() && !cmd.Flags.Hidden() {
+			cmd.LoadDescription()
+			if len(listName(cmd)) > padLen {
+				padLen = len(listName(cmd))
+			}
+			grouped = grouped || (!cmd.Builtin && len(cmd.Group) > 0)
 		}
 	}
+	if !grouped {
+		listCommandSection("Commands", config.CommandList, padLen)
+		return
+	}
+	var (
+		ungrouped []*config.Command
+		builtins  []*config.Command
+		groups    []string
+		byGroup   = make(map[string][]*config.Command)
+	)
 	for _, cmd := range config.CommandList {
+		switch {
+		case cmd.Builtin:
+			builtins = append(builtins, cmd)
+		case len(cmd.Group) == 0:
+			ungrouped = append(ungrouped, cmd)
+		default:
+			if _, ok := byGroup[cmd.Group]; !ok {
+				groups = append(groups, cmd.Group)
+			}
+			byGroup[cmd.Group] = append(byGroup[cmd.Group], cmd)
+		}
+	}
+	needsNewline := listCommandSection("Commands", ungrouped, padLen)
+	for _, group := range groups {
+		if needsNewline {
+			_, _ = fmt.Fprintln(config.ErrOut)
+		}
+		needsNewline = listCommandSection(group, byGroup[group], padLen) || needsNewline
+	}
+	if needsNewline {
+		_, _ = fmt.Fprintln(config.ErrOut)
+	}
+	listCommandSection("Builtins", builtins, padLen)
+}
+
+// listCommandSection prints a header followed by the visible commands in the list.
+// Returns false, printing nothing, if no commands are visible.
+//
+func listCommandSection(header string, cmds []*config.Command, padLen int) bool {
+	printed := false
+	for _, cmd := range cmds {
 		if !cmd.Flags.Private() && !cmd.Flags.Hidden() {
-			_, _ = fmt.Fprintf(config.ErrOut, "  %s%s    %s\n", cmd.Name, strings.Repeat(" ", padLen-len(cmd.Name)), cmd.Title)
+			if !printed {
+				_, _ = fmt.Fprintf(config.ErrOut, "%s:\n", header)
+				printed = true
+			}
+			cmd.LoadDescription()
+			name := listName(cmd)
+			_, _ = fmt.Fprintf(config.ErrOut, "  %s%s    %s\n", name, strings.Repeat(" ", padLen-len(name)), cmd.Title)
 		}
 	}
+	return printed
 }
 
 // RunHelp shows help for the specified command.
@@ -425,6 +561,58 @@ func ListCommands() {
 // If no command given, prints usage message and returns exit code 2
 //
 func RunHelp() int {
+	c, _ := findCommandFromArgs("help")
+	if c == nil {
+		return 2
+	}
+	c.Help()
+	return 0
+}
+
+// RunWhich shows where the specified command is defined.
+// On success, returns exit code 0
+// If command not found, prints error message and returns exit code 2
+// If no command given, prints usage message and returns exit code 2
+//
+//goland:noinspection GoUnhandledErrorResult // fmt.*
+func RunWhich(builtinName string) int {
+	c, cmdName := findCommandFromArgs(builtinName)
+	if c == nil {
+		return 2
+	}
+	if c.Builtin {
+		fmt.Fprintf(config.ErrOut, "%s: builtin command\n", c.Name)
+		return 0
+	}
+	if cmdName != strings.ToLower(c.Name) {
+		fmt.Fprintf(config.ErrOut, "%s: alias for %s\n", cmdName, c.Name)
+	}
+	fmt.Fprintf(config.ErrOut, "%s:\n", c.Name)
+	fmt.Fprintln(config.ErrOut, "Definitions (in override order):")
+	for i, def := range c.Definitions {
+		active := ""
+		if i == len(c.Definitions)-1 {
+			active = " (active)"
+		}
+		fmt.Fprintf(config.ErrOut, "  %s:%d%s\n", def.Runfile, def.Line, active)
+	}
+	fmt.Fprintln(config.ErrOut, "Description From:")
+	if c.DescFrom != nil {
+		fmt.Fprintf(config.ErrOut, "  %s:%d\n", c.DescFrom.Runfile, c.DescFrom.Line)
+	} else {
+		fmt.Fprintln(config.ErrOut, "  (none)")
+	}
+	fmt.Fprintln(config.ErrOut, "Shell:")
+	fmt.Fprintf(config.ErrOut, "  %s\n", c.Definitions[len(c.Definitions)-1].Shell)
+	return 0
+}
+
+// findCommandFromArgs resolves the command named by the leading args, consuming them from os.Args.
+// Used by builtins that operate on another command, i.e. 'help <command>'.
+// Returns the command along with the (normalized) name it was resolved by.
+// If command not found, or no command given, prints an error message and returns nil.
+//
+func findCommandFromArgs(builtinName string) (*config.Command, string) {
 	var cmdName string
 	var cmdShowHidden bool
 	if len(os.Args) > 0 {
@@ -438,28 +626,153 @@ func RunHelp() int {
 			cmdName = strings.TrimPrefix(cmdName, ".")
 			cmdShowHidden = true
 		}
-		cmdName = strings.ToLower(cmdName)
-		if c, ok := config.CommandMap[cmdName]; ok && !c.Flags.Private() && (!c.Flags.Hidden() || cmdShowHidden) {
-			c.Help()
-			return 0
+		cmdName, os.Args = ResolveCommandPath(cmdName, os.Args, cmdShowHidden)
+		prefixName, prefixMatches := ResolveCommandPrefix(cmdName, cmdShowHidden)
+		if len(prefixMatches) > 1 {
+			log.Printf("ambiguous command: %s\n\n", cmdName) // 2 x \n
+			ListCommandSuggestions(prefixMatches)
+			return nil, cmdName
 		}
-		// NOTE: No further 'see' messages when help invoked *with* a command
+		cmdName = prefixName
+		c, ok := config.CommandMap[cmdName]
+		if ok && !c.Flags.Private() && (!c.Flags.Hidden() || cmdShowHidden) {
+			return c, cmdName
+		}
+		// Parent of sub-commands? List them
+		//
+		if !ok && HasSubCommands(cmdName, cmdShowHidden) {
+			ListSubCommands(cmdName, cmdShowHidden)
+			return nil, cmdName
+		}
+		// NOTE: No further 'see' messages when invoked *with* a command
 		//
 		log.Printf("command not found: %s\n\n", cmdName) // 2 x \n
-		ListCommands()
+		ListCommandsOrSuggestions(cmdName, cmdShowHidden)
 	} else {
-		_, _ = fmt.Fprintf(config.ErrOut, "usage: '%s help <command>'\n\n", config.Me) // 2 x \n
+		_, _ = fmt.Fprintf(config.ErrOut, "usage: '%s %s <command>'\n\n", config.Me, builtinName) // 2 x \n
 		ListCommands()
 		_, _ = fmt.Fprintf(config.ErrOut, "\nsee '%s --help' for more information\n", config.Me) // Leading \n
 	}
-	return 2
+	return nil, cmdName
+}
+
+// LookupCommand finds a command referenced from within the specified namespace.
+// The name is resolved against the namespace first, then each parent namespace,
+// and finally the top-level (un-namespaced) commands.
+//
+func LookupCommand(namespace string, name string) (*config.Command, bool) {
+	namespace = strings.ToLower(namespace) // Normalize
+	for len(namespace) > 0 {
+		if cmd, ok := config.CommandMap[namespace+":"+name]; ok {
+			return cmd, true
+		}
+		if i := strings.LastIndex(namespace, ":"); i >= 0 {
+			namespace = namespace[:i]
+		} else {
+			namespace = ""
+		}
+	}
+	cmd, ok := config.CommandMap[name]
+	return cmd, ok
+}
+
+// RunHooks runs the BEFORE.ALL / AFTER.ALL hooks that apply to the named (normalized) command.
+// The provided env is exported to each hook.
+// Returns the exit code of the first failing hook, else 0
+//
+func RunHooks(hooks []*Hook, cmdName string, env map[string]string, out io.Writer) int {
+	for _, hook := range hooks {
+		hookName := strings.ToLower(hook.Command) // Normalize
+		// Hooks don't wrap themselves
+		//
+		if hookName == cmdName || !hook.Matches(cmdName) {
+			continue
+		}
+		var cmdMapEntry *config.Command
+		var cmdExists bool
+		if cmdMapEntry, cmdExists = LookupCommand(hook.Namespace, hookName); !cmdExists {
+			log.Printf("ERROR: %s:%d: command not found: %s", hook.Runfile, hook.Line, hookName)
+			return 2
+		}
+		if cmdMapEntry.Builtin {
+			log.Printf("ERROR: %s:%d: cannot RUN builtin command: %s", hook.Runfile, hook.Line, hookName)
+			return 2
+		}
+		hookName = strings.ToLower(cmdMapEntry.Name) // Canonical name, in case of alias
+		if hookName == cmdName {
+			continue
+		}
+		if _, exists := config.RunCycleMap[hookName]; exists {
+			log.Printf("ERROR: %s:%d: Running cmd %s again would cause an infinite loop", hook.Runfile, hook.Line, hookName)
+			return 2
+		}
+		// Mark command as run
+		//
+		config.RunCycleMap[hookName] = struct{}{}
+		exitCode := cmdMapEntry.Run([]string{}, env, out)
+		// Clear command from run map
+		//
+		delete(config.RunCycleMap, hookName)
+		if exitCode != 0 {
+			return exitCode
+		}
+	}
+	return 0
+}
+
+// resolveRunCmd resolves the command to invoke for a RUN action, along with its arg list(s).
+// RUN.EACH may result in multiple arg lists, one per invocation.
+// RUN ^ resolves to the overridden command, invoked with the original args unless args are specified.
+// Returns the canonical name of the command to track in config.RunCycleMap,
+// or "" for RUN ^, as the overridden command is a different definition of the running command.
+// On error, logs the error and returns false.
+//
+func resolveRunCmd(cmd *RunCmd, super *config.Command, runCmd *RunCmdRun, superArgs []string) (*config.Command, string, [][]string, bool) {
+	if runCmd.Command == SuperCommand {
+		if super == nil {
+			log.Printf("ERROR: %s:%d: cannot RUN %s: command %s does not override another command", cmd.Runfile, cmd.Line, SuperCommand, cmd.Name)
+			return nil, "", nil, false
+		}
+		if len(runCmd.Args) > 0 {
+			return super, "", runCmd.Invocations(), true
+		}
+		return super, "", [][]string{superArgs}, true
+	}
+	cmdName := strings.ToLower(runCmd.Command) // Normalize
+	var cmdMapEntry *config.Command
+	var cmdExists bool
+	if cmdMapEntry, cmdExists = LookupCommand(cmd.Namespace, cmdName); !cmdExists {
+		log.Printf("ERROR: %s:%d: command not found: %s", cmd.Runfile, cmd.Line, cmdName)
+		return nil, "", nil, false
+	}
+	if cmdMapEntry.Builtin {
+		log.Printf("ERROR: %s:%d: cannot RUN builtin command: %s", cmd.Runfile, cmd.Line, cmdName)
+		return nil, "", nil, false
+	}
+	cmdName = strings.ToLower(cmdMapEntry.Name) // Canonical name, in case of alias
+	if _, exists := config.RunCycleMap[cmdName]; exists {
+		log.Printf("ERROR: %s:%d: Running cmd %s again would cause an infinite loop", cmd.Runfile, cmd.Line, cmdName)
+		return nil, "", nil, false
+	}
+	return cmdMapEntry, cmdName, runCmd.Invocations(), true
+}
+
+// markRunCmd marks the (canonical) command name as run, to avoid RUN loops.
+// Empty names are ignored.
+//
+func markRunCmd(cmdName string) {
+	if len(cmdName) > 0 {
+		config.RunCycleMap[cmdName] = struct{}{}
+	}
 }
 
 // RunCommand executes a command returning an exit code
+// super is the command overridden by this command, if any, invoked via 'RUN ^'.
 //
-func RunCommand(cmdProvider CmdProvider, rf *Runfile, args []string, env map[string]string, out io.Writer) int {
+func RunCommand(cmdProvider CmdProvider, rf *Runfile, super *config.Command, args []string, env map[string]string, out io.Writer) int {
 	cmd := cmdProvider.GetCmdEnv(rf, env)
 	exitCode := 0
+	superArgs := args // RUN ^ invokes the overridden command with the original args
 	args, exitCode = evaluateCmdOpts(cmd, args)
 	if exitCode != 0 {
 		return exitCode
@@ -469,16 +782,12 @@ func RunCommand(cmdProvider CmdProvider, rf *Runfile, args []string, env map[str
 	// but their values may have changed, so we re-fetch them
 	//
 	for varName := range env {
-		if value, ok := cmd.Scope.GetVar(varName); ok {
-			cmdEnv[varName] = value
-		} else {
+		if !cmd.Scope.AddVarToEnv(cmdEnv, varName) {
 			log.Printf("WARNING: exported variable not defined: '%s'", varName)
 		}
 	}
 	for _, export := range cmd.Scope.GetVarExports() {
-		if value, ok := cmd.Scope.GetVar(export.VarName); ok {
-			cmdEnv[export.VarName] = value
-		} else {
+		if !cmd.Scope.AddVarToEnv(cmdEnv, export.VarName) {
 			log.Printf("WARNING: exported variable not defined: '%s'", export.VarName)
 		}
 	}
@@ -492,45 +801,36 @@ func RunCommand(cmdProvider CmdProvider, rf *Runfile, args []string, env map[str
 	// Run 'Env' Commands - Runs BEFORE Asserts
 	//
 	for _, runCmd := range cmd.Config.EnvRuns {
-		cmdName := strings.ToLower(runCmd.Command) // Normalize
-		var cmdMapEntry *config.Command
-		var cmdExists bool
-		if cmdMapEntry, cmdExists = config.CommandMap[cmdName]; !cmdExists {
-			log.Printf("ERROR: %s:%d: command not found: %s", cmd.Runfile, cmd.Line, cmdName)
+		cmdMapEntry, cmdName, runArgsList, ok := resolveRunCmd(cmd, super, runCmd, superArgs)
+		if !ok {
 			return 2
 		}
-		if cmdMapEntry.Builtin {
-			log.Printf("ERROR: %s:%d: cannot RUN builtin command: %s", cmd.Runfile, cmd.Line, cmdName)
-			return 2
-		}
-		if _, exists := config.RunCycleMap[cmdName]; exists {
-			log.Printf("ERROR: %s:%d: Running cmd %s again would cause an infinite loop", cmd.Runfile, cmd.Line, cmdName)
-			return 2
-		}
-		// Mark command as run
-		//
-		config.RunCycleMap[cmdName] = struct{}{}
-		capturedOutput := &strings.Builder{}
-		exitCode = cmdMapEntry.Run(runCmd.Args, cmdEnv, capturedOutput)
-		// Clear command from run map
-		//
-		delete(config.RunCycleMap, cmdName)
-		// Exit on error
-		//
-		if exitCode != 0 {
-			return exitCode
-		}
-		// Process ENV response
-		//
-		dotEnv, err := gotenv.StrictParse(strings.NewReader(capturedOutput.String()))
-		if err != nil {
-			log.Printf("ERROR: %s:%d: while processing ENV output from cmd %s: %s", cmd.Runfile, cmd.Line, cmdName, err)
-			return 2
-		}
-		// All values exported
-		//
-		for k, v := range dotEnv {
-			cmdEnv[k] = v
+		for _, runArgs := range runArgsList {
+			// Mark command as run
+			//
+			markRunCmd(cmdName)
+			capturedOutput := &strings.Builder{}
+			exitCode = cmdMapEntry.Run(runArgs, cmdEnv, capturedOutput)
+			// Clear command from run map
+			//
+			delete(config.RunCycleMap, cmdName)
+			// Exit on error
+			//
+			if exitCode != 0 {
+				return exitCode
+			}
+			// Process ENV response
+			//
+			dotEnv, err := gotenv.StrictParse(strings.NewReader(capturedOutput.String()))
+			if err != nil {
+				log.Printf("ERROR: %s:%d: while processing ENV output from cmd %s: %s", cmd.Runfile, cmd.Line, cmdName, err)
+				return 2
+			}
+			// All values exported
+			//
+			for k, v := range dotEnv {
+				cmdEnv[k] = v
+			}
 		}
 	}
 	// Check Asserts - Uses global .SHELL
@@ -540,11 +840,11 @@ func RunCommand(cmdProvider CmdProvider, rf *Runfile, args []string, env map[str
 		shell = config.DefaultShell
 	}
 	for _, assert := range cmd.Scope.Asserts {
-		if exec.ExecuteTest(shell, assert.Test, cmdEnv) != 0 {
+		if exec.ExecuteTest(shell, assert.Test.Get(), cmdEnv) != 0 {
 			// Print message if one configured
 			//
-			if len(assert.Message) > 0 {
-				log.Printf("ERROR: %s:%d: %s", assert.Runfile, assert.Line, assert.Message)
+			if message := strings.TrimSpace(assert.Message.Get()); len(message) > 0 {
+				log.Printf("ERROR: %s:%d: %s", assert.Runfile, assert.Line, message)
 			} else {
 				log.Printf("ERROR: %s:%d: assertion failed", assert.Runfile, assert.Line)
 			}
@@ -555,66 +855,52 @@ func RunCommand(cmdProvider CmdProvider, rf *Runfile, args []string, env map[str
 	// Run 'Before' Commands
 	//
 	for _, runCmd := range cmd.Config.BeforeRuns {
-		cmdName := strings.ToLower(runCmd.Command) // Normalize
-		var cmdMapEntry *config.Command
-		var cmdExists bool
-		if cmdMapEntry, cmdExists = config.CommandMap[cmdName]; !cmdExists {
-			log.Printf("ERROR: %s:%d: command not found: %s", cmd.Runfile, cmd.Line, cmdName)
+		cmdMapEntry, cmdName, runArgsList, ok := resolveRunCmd(cmd, super, runCmd, superArgs)
+		if !ok {
 			return 2
 		}
-		if cmdMapEntry.Builtin {
-			log.Printf("ERROR: %s:%d: cannot RUN builtin command: %s", cmd.Runfile, cmd.Line, cmdName)
-			return 2
-		}
-		if _, exists := config.RunCycleMap[cmdName]; exists {
-			log.Printf("ERROR: %s:%d: Running cmd %s again would cause an infinite loop", cmd.Runfile, cmd.Line, cmdName)
-			return 2
-		}
-		// Mark command as run
-		//
-		config.RunCycleMap[cmdName] = struct{}{}
-		exitCode = cmdMapEntry.Run(runCmd.Args, cmdEnv, out)
-		// Clear command from run map
-		//
-		delete(config.RunCycleMap, cmdName)
-		if exitCode != 0 {
-			return exitCode
+		for _, runArgs := range runArgsList {
+			// Mark command as run
+			//
+			markRunCmd(cmdName)
+			exitCode = cmdMapEntry.Run(runArgs, cmdEnv, out)
+			// Clear command from run map
+			//
+			delete(config.RunCycleMap, cmdName)
+			if exitCode != 0 {
+				return exitCode
+			}
 		}
 	}
 	// Execute script - Uses cmd shell
 	//
 	shell = cmd.Shell()
-	exitCode = exec.ExecuteCmdScript(shell, cmd.Script, args, cmdEnv, out)
+	if len(cmd.Config.Foreach) > 0 {
+		exitCode = runForeach(cmd, shell, args, cmdEnv, out)
+	} else {
+		exitCode = exec.ExecuteCmdScript(shell, cmd.Script, args, cmdEnv, out, os.Stderr)
+	}
 	if exitCode != 0 {
 		return exitCode
 	}
 	// Run 'After' Commands
 	//
 	for _, runCmd := range cmd.Config.AfterRuns {
-		cmdName := strings.ToLower(runCmd.Command) // Normalize
-		var cmdMapEntry *config.Command
-		var cmdExists bool
-		if cmdMapEntry, cmdExists = config.CommandMap[cmdName]; !cmdExists {
-			log.Printf("ERROR: %s:%d: command not found: %s", cmd.Runfile, cmd.Line, cmdName)
-			return 2
-		}
-		if cmdMapEntry.Builtin {
-			log.Printf("ERROR: %s:%d: cannot RUN builtin command: %s", cmd.Runfile, cmd.Line, cmdName)
-			return 2
-		}
-		if _, exists := config.RunCycleMap[cmdName]; exists {
-			log.Printf("ERROR: %s:%d: Running cmd %s again would cause an infinite loop", cmd.Runfile, cmd.Line, cmdName)
+		cmdMapEntry, cmdName, runArgsList, ok := resolveRunCmd(cmd, super, runCmd, superArgs)
+		if !ok {
 			return 2
 		}
-		// Mark command as run
-		//
-		config.RunCycleMap[cmdName] = struct{}{}
-		exitCode = cmdMapEntry.Run(runCmd.Args, cmdEnv, out)
-		// Clear command from run map
-		//
-		delete(config.RunCycleMap, cmdName)
-		if exitCode != 0 {
-			return exitCode
+		for _, runArgs := range runArgsList {
+			// Mark command as run
+			//
+			markRunCmd(cmdName)
+			exitCode = cmdMapEntry.Run(runArgs, cmdEnv, out)
+			// Clear command from run map
+			//
+			delete(config.RunCycleMap, cmdName)
+			if exitCode != 0 {
+				return exitCode
+			}
 		}
 	}
 	return exitCode
@@ -0,0 +1,33 @@
+package runfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitGlobalOptArgs(t *testing.T) {
+	opts := []*RunCmdOpt{
+		{Name: "VERBOSE", Short: 'v', Long: "verbose"},
+		{Name: "PROFILE", Long: "profile", Example: "name"},
+	}
+	tests := []struct {
+		args       []string
+		wantGlobal []string
+		wantOther  []string
+	}{
+		{[]string{"-run", "TestFoo", "./..."}, nil, []string{"-run", "TestFoo", "./..."}},
+		{[]string{"-v", "-run", "X"}, []string{"-v"}, []string{"-run", "X"}},
+		{[]string{"--verbose=false", "x"}, []string{"--verbose=false"}, []string{"x"}},
+		{[]string{"a", "--profile", "prod", "b"}, []string{"--profile", "prod"}, []string{"a", "b"}},
+		{[]string{"-profile=prod", "-count", "1"}, []string{"-profile=prod"}, []string{"-count", "1"}},
+		{[]string{"--PROFILE", "prod"}, []string{"--PROFILE", "prod"}, nil},
+		{[]string{"-x", "--", "-v", "--profile", "prod"}, nil, []string{"-x", "--", "-v", "--profile", "prod"}},
+		{[]string{"-", "-h"}, nil, []string{"-", "-h"}},
+	}
+	for _, test := range tests {
+		gotGlobal, gotOther := splitGlobalOptArgs(opts, test.args)
+		if !reflect.DeepEqual(gotGlobal, test.wantGlobal) || !reflect.DeepEqual(gotOther, test.wantOther) {
+			t.Errorf("splitGlobalOptArgs(%q) = %q, %q, want %q, %q", test.args, gotGlobal, gotOther, test.wantGlobal, test.wantOther)
+		}
+	}
+}
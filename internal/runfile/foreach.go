@@ -0,0 +1,97 @@
+package runfile
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/tekwizely/run/internal/config"
+	"github.com/tekwizely/run/internal/exec"
+	"github.com/tekwizely/run/internal/util"
+)
+
+// foreachCell captures a single combination of FOREACH values.
+//
+type foreachCell struct {
+	vars     []string          // NAME=value pairs, for reporting
+	env      map[string]string // Command env, plus the FOREACH values
+	exitCode int
+}
+
+// foreachCells returns the combinations of FOREACH values.
+// Combinations are ordered with the last FOREACH variable varying fastest.
+//
+func foreachCells(foreach []*RunCmdForeach, env map[string]string) []*foreachCell {
+	cells := []*foreachCell{{}}
+	for _, each := range foreach {
+		values := each.Values.Items()
+		next := make([]*foreachCell, 0, len(cells)*len(values))
+		for _, cell := range cells {
+			for _, value := range values {
+				vars := append(append([]string{}, cell.vars...), each.Name+"="+value)
+				next = append(next, &foreachCell{vars: vars})
+			}
+		}
+		cells = next
+	}
+	for _, cell := range cells {
+		cell.env = make(map[string]string, len(env)+len(cell.vars))
+		for k, v := range env {
+			cell.env[k] = v
+		}
+		for _, pair := range cell.vars {
+			i := strings.IndexByte(pair, '=')
+			cell.env[pair[:i]] = pair[i+1:]
+		}
+	}
+	return cells
+}
+
+// foreachJobs returns the maximum number of combinations to execute in parallel.
+// Defaults to the number of CPUs, configurable via the .FOREACH.JOBS attribute.
+//
+func foreachJobs(cmd *RunCmd) int {
+	value, _ := cmd.Scope.GetAttr(".FOREACH.JOBS")
+	jobs, err := util.ParseJobs(".FOREACH.JOBS", value)
+	if err != nil {
+		panic(fmt.Errorf("%s:%d: %s", cmd.Runfile, cmd.Line, err))
+	}
+	return jobs
+}
+
+// runForeach executes the command script once per combination of FOREACH values,
+// with the values exported, either sequentially or in parallel (see util.RunParallel).
+// All combinations are executed, even if one fails.
+// Reports a pass/fail table, returning the exit code of the first failed combination, if any.
+//
+func runForeach(cmd *RunCmd, shell string, args []string, env map[string]string, out io.Writer) int {
+	cells := foreachCells(cmd.Config.Foreach, env)
+	if len(cells) == 0 {
+		log.Printf("WARNING: %s:%d: FOREACH has no values, script not executed", cmd.Runfile, cmd.Line)
+		return 0
+	}
+	if cmd.Config.Parallel {
+		exitCodes := util.RunParallel(len(cells), foreachJobs(cmd), func(i int, stdout io.Writer, stderr io.Writer) int {
+			return exec.ExecuteCmdScriptNoStdin(shell, cmd.Script, args, cells[i].env, stdout, stderr)
+		}, func(i int, _ int) {
+			_, _ = fmt.Fprintf(config.ErrOut, "==> %s: %s\n", cmd.Name, strings.Join(cells[i].vars, " "))
+		}, out, os.Stderr)
+		for i, exitCode := range exitCodes {
+			cells[i].exitCode = exitCode
+		}
+	} else {
+		for _, cell := range cells {
+			_, _ = fmt.Fprintf(config.ErrOut, "==> %s: %s\n", cmd.Name, strings.Join(cell.vars, " "))
+			cell.exitCode = exec.ExecuteCmdScript(shell, cmd.Script, args, cell.env, out, os.Stderr)
+		}
+	}
+	// Report
+	//
+	results := make([]util.JobResult, len(cells))
+	for i, cell := range cells {
+		results[i] = util.JobResult{Name: strings.Join(cell.vars, " "), ExitCode: cell.exitCode}
+	}
+	return util.ReportJobResults(config.ErrOut, cmd.Name+": FOREACH results:", results)
+}
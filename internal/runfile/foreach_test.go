@@ -0,0 +1,120 @@
+package runfile
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/tekwizely/run/internal/config"
+)
+
+// foreachCmd creates a FOREACH command, executing the script once per value of V.
+//
+func foreachCmd(t *testing.T, script string, parallel bool, values ...string) *RunCmd {
+	errOut := config.ErrOut
+	t.Cleanup(func() { config.ErrOut = errOut })
+	config.ErrOut = ioutil.Discard
+	return &RunCmd{
+		Name:    "test",
+		Scope:   NewScope(),
+		Script:  []string{script + "\n"},
+		Runfile: "Runfile",
+		Line:    1,
+		Config: &RunCmdConfig{
+			Parallel: parallel,
+			Foreach: []*RunCmdForeach{{
+				Name:   "V",
+				Values: NewLazyList(func() []string { return values }),
+			}},
+		},
+	}
+}
+
+func TestForeach(t *testing.T) {
+	for _, parallel := range []bool{false, true} {
+		cmd := foreachCmd(t, "echo \"v=${V}\"\n[ \"${V}\" != b ] || exit 3", parallel, "a", "b", "c")
+		out := &bytes.Buffer{}
+		if exitCode := runForeach(cmd, "sh", []string{}, map[string]string{}, out); exitCode != 3 {
+			t.Errorf("parallel=%v: exit code = %d, want 3", parallel, exitCode)
+		}
+		for _, want := range []string{"v=a\n", "v=b\n", "v=c\n"} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("parallel=%v: output %q missing %q", parallel, out.String(), want)
+			}
+		}
+	}
+}
+
+func TestForeachParallelJobs(t *testing.T) {
+	dir := t.TempDir()
+	// Each combination counts the combinations running alongside it
+	//
+	cmd := foreachCmd(t, "touch \""+dir+"/${V}\"; sleep 0.1; ls \""+dir+"\" | wc -l | tr -d ' '; rm \""+dir+"/${V}\"", true, "a", "b", "c", "d")
+	cmd.Scope.PutAttr(".FOREACH.JOBS", "1")
+	out := &bytes.Buffer{}
+	if exitCode := runForeach(cmd, "sh", []string{}, map[string]string{}, out); exitCode != 0 {
+		t.Errorf("exit code = %d, want 0", exitCode)
+	}
+	if got := out.String(); got != "1\n1\n1\n1\n" {
+		t.Errorf("output = %q, want %q", got, "1\n1\n1\n1\n")
+	}
+}
+
+func TestForeachParallelJobsInvalid(t *testing.T) {
+	cmd := foreachCmd(t, "true", true, "a")
+	cmd.Scope.PutAttr(".FOREACH.JOBS", "0")
+	defer func() {
+		if r := recover(); r == nil || !strings.Contains(r.(error).Error(), "Runfile:1: .FOREACH.JOBS: expecting a positive number, got '0'") {
+			t.Errorf("got panic %v, want invalid .FOREACH.JOBS error", r)
+		}
+	}()
+	runForeach(cmd, "sh", []string{}, map[string]string{}, ioutil.Discard)
+}
+
+func TestForeachParallelNoStdin(t *testing.T) {
+	stdin, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.WriteString("input\n")
+	_ = w.Close()
+	osStdin := os.Stdin
+	t.Cleanup(func() { os.Stdin = osStdin; _ = stdin.Close() })
+	os.Stdin = stdin
+	// read fails at EOF
+	//
+	cmd := foreachCmd(t, "if read line; then exit 1; fi", true, "a", "b")
+	if exitCode := runForeach(cmd, "sh", []string{}, map[string]string{}, ioutil.Discard); exitCode != 0 {
+		t.Errorf("exit code = %d, want 0", exitCode)
+	}
+}
+
+func TestForeachParallelPanic(t *testing.T) {
+	// An empty shell panics within the goroutine
+	//
+	cmd := foreachCmd(t, "true", true, "a", "b")
+	if exitCode := runForeach(cmd, "", []string{}, map[string]string{}, ioutil.Discard); exitCode != 1 {
+		t.Errorf("exit code = %d, want 1", exitCode)
+	}
+}
+
+func TestForeachCells(t *testing.T) {
+	foreach := []*RunCmdForeach{
+		{Name: "A", Values: NewList([]string{"1", "2"})},
+		{Name: "B", Values: NewList([]string{"x", "y z"})},
+	}
+	cells := foreachCells(foreach, map[string]string{"C": "c"})
+	var got []string
+	for _, cell := range cells {
+		got = append(got, strings.Join(cell.vars, ",")+"|"+cell.env["A"]+cell.env["B"]+cell.env["C"])
+	}
+	want := []string{"A=1,B=x|1xc", "A=1,B=y z|1y zc", "A=2,B=x|2xc", "A=2,B=y z|2y zc"}
+	if strings.Join(got, ";") != strings.Join(want, ";") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if cells := foreachCells([]*RunCmdForeach{{Name: "A", Values: NewList(nil)}}, nil); len(cells) != 0 {
+		t.Errorf("no values: got %d cells, want 0", len(cells))
+	}
+}
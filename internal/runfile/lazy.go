@@ -0,0 +1,92 @@
+package runfile
+
+import "strings"
+
+// LazyValue is a value that is only evaluated when first needed.
+// The result is memoized, so the value is evaluated at most once per invocation.
+// A value may be a list, in which case its string value is the items, joined with a space.
+//
+type LazyValue struct {
+	eval     func() string
+	evalList func() []string
+	value    string
+	items    []string
+	isList   bool
+}
+
+// NewValue is a convenience method, returning an already-evaluated value.
+//
+func NewValue(value string) *LazyValue {
+	return &LazyValue{value: value}
+}
+
+// NewLazyValue returns a value that invokes eval when first needed.
+//
+func NewLazyValue(eval func() string) *LazyValue {
+	return &LazyValue{eval: eval}
+}
+
+// NewList is a convenience method, returning an already-evaluated list.
+//
+func NewList(items []string) *LazyValue {
+	return &LazyValue{items: items, isList: true}
+}
+
+// NewLazyList returns a list that invokes eval when first needed.
+//
+func NewLazyList(eval func() []string) *LazyValue {
+	return &LazyValue{evalList: eval, isList: true}
+}
+
+// IsList returns true if the value is a list.
+//
+func (v *LazyValue) IsList() bool {
+	return v.isList
+}
+
+// Get evaluates the value if needed, returning the (memoized) result.
+//
+func (v *LazyValue) Get() string {
+	if v.isList {
+		return strings.Join(v.Items(), " ")
+	}
+	if v.eval != nil {
+		eval := v.eval
+		v.eval = nil // Clear first, guarding against re-entry
+		v.value = eval()
+	}
+	return v.value
+}
+
+// Items evaluates the list if needed, returning the (memoized) items.
+// Non-list values are treated as a single-item list, or an empty list if the value is empty.
+//
+func (v *LazyValue) Items() []string {
+	if !v.isList {
+		if value := v.Get(); len(value) > 0 {
+			return []string{value}
+		}
+		return []string{}
+	}
+	if v.evalList != nil {
+		eval := v.evalList
+		v.evalList = nil // Clear first, guarding against re-entry
+		v.items = eval()
+	}
+	return v.items
+}
+
+// LazyValues evaluates a list of values.
+// List values are spliced into the result.
+//
+func LazyValues(values []*LazyValue) []string {
+	result := make([]string, 0, len(values))
+	for _, v := range values {
+		if v.IsList() {
+			result = append(result, v.Items()...)
+		} else {
+			result = append(result, v.Get())
+		}
+	}
+	return result
+}
@@ -0,0 +1,278 @@
+package runfile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/tekwizely/run/internal/config"
+	"github.com/tekwizely/run/internal/util"
+)
+
+// ResolveCommandPath resolves the longest sequence of leading args that names a command.
+// Args are joined with '/', so 'db migrate up' can resolve to the command 'db/migrate/up'.
+// If no command matches, the longest sequence that is a parent of other commands is used.
+// If prefix matching is enabled, each segment can be a unique prefix, i.e. 'd mi up' => 'db/migrate/up'.
+// Returns the normalized command name along with the remaining args.
+//
+func ResolveCommandPath(name string, args []string, showHidden bool) (string, []string) {
+	name = strings.ToLower(name) // Normalize
+	var (
+		resolved = name
+		consumed = 0
+		found    = false
+	)
+	if found = isVisibleCommand(name, showHidden); !found && !HasSubCommands(name, showHidden) {
+		// Only resolve a leading prefix here if it names a parent,
+		// leaving ResolveCommandPrefix to report on top-level commands
+		//
+		segment, ok := resolveSegmentPrefix("", name, showHidden)
+		if !ok || !HasSubCommands(segment, showHidden) {
+			return name, args
+		}
+		resolved, name, found = segment, segment, isVisibleCommand(segment, showHidden)
+	}
+	cmdPath := name
+	for i, arg := range args {
+		nextPath := cmdPath + "/" + strings.ToLower(arg)
+		if !isVisibleCommand(nextPath, showHidden) && !HasSubCommands(nextPath, showHidden) {
+			segment, ok := resolveSegmentPrefix(cmdPath, strings.ToLower(arg), showHidden)
+			if !ok {
+				break
+			}
+			nextPath = cmdPath + "/" + segment
+		}
+		cmdPath = nextPath
+		if isVisibleCommand(cmdPath, showHidden) {
+			resolved, consumed, found = cmdPath, i+1, true
+		} else if !found {
+			resolved, consumed = cmdPath, i+1
+		}
+	}
+	return resolved, args[consumed:]
+}
+
+// HasSubCommands returns true if any visible commands are registered beneath the (normalized) path.
+//
+func HasSubCommands(cmdPath string, showHidden bool) bool {
+	prefix := cmdPath + "/"
+	for name, cmd := range config.CommandMap {
+		if strings.HasPrefix(name, prefix) && isVisible(cmd, showHidden) {
+			return true
+		}
+	}
+	return false
+}
+
+// isVisibleCommand returns true if the (normalized) name is a command that can be invoked by name.
+//
+func isVisibleCommand(name string, showHidden bool) bool {
+	cmd, ok := config.CommandMap[name]
+	return ok && isVisible(cmd, showHidden)
+}
+
+// resolveSegmentPrefix resolves a (normalized) path segment to the unique segment beneath the parent path that it is a prefix of.
+// The parent path is empty for top-level segments.
+// Only applies if config.PrefixMatch is enabled.
+//
+func resolveSegmentPrefix(parent string, segment string, showHidden bool) (string, bool) {
+	if !config.PrefixMatch {
+		return "", false
+	}
+	prefix := ""
+	if len(parent) > 0 {
+		prefix = parent + "/"
+	}
+	var match string
+	for name, cmd := range config.CommandMap {
+		if !strings.HasPrefix(name, prefix) || !isVisible(cmd, showHidden) {
+			continue
+		}
+		next := strings.SplitN(name[len(prefix):], "/", 2)[0]
+		if !strings.HasPrefix(next, segment) {
+			continue
+		}
+		if len(match) > 0 && match != next {
+			return "", false
+		}
+		match = next
+	}
+	return match, len(match) > 0
+}
+
+// subCommandNode is an entry in the tree of sub-commands beneath a path.
+// cmd is nil for intermediate paths that are not themselves commands.
+//
+type subCommandNode struct {
+	name     string
+	cmd      *config.Command
+	children []*subCommandNode
+}
+
+// child fetches the named child node, creating it if needed.
+//
+func (n *subCommandNode) child(name string) *subCommandNode {
+	for _, c := range n.children {
+		if strings.EqualFold(c.name, name) {
+			return c
+		}
+	}
+	c := &subCommandNode{name: name}
+	n.children = append(n.children, c)
+	return c
+}
+
+// ListSubCommands prints the nested list of visible commands beneath the (normalized) path.
+// Commands are listed in the order they are registered.
+//
+func ListSubCommands(cmdPath string, showHidden bool) {
+	root := &subCommandNode{}
+	prefix := cmdPath + "/"
+	for _, cmd := range config.CommandList {
+		if !isVisible(cmd, showHidden) || !strings.HasPrefix(strings.ToLower(cmd.Name), prefix) {
+			continue
+		}
+		node := root
+		for _, segment := range strings.Split(cmd.Name[len(prefix):], "/") {
+			node = node.child(segment)
+		}
+		node.cmd = cmd
+	}
+	padLen := 0
+	var measure func(n *subCommandNode, depth int)
+	measure = func(n *subCommandNode, depth int) {
+		for _, c := range n.children {
+			if l := depth*2 + len(subCommandListName(c)); l > padLen {
+				padLen = l
+			}
+			measure(c, depth+1)
+		}
+	}
+	measure(root, 0)
+	_, _ = fmt.Fprintf(config.ErrOut, "%s:\n", cmdPath)
+	var list func(n *subCommandNode, depth int)
+	list = func(n *subCommandNode, depth int) {
+		for _, c := range n.children {
+			name := strings.Repeat(" ", depth*2) + subCommandListName(c)
+			title := ""
+			if c.cmd != nil {
+				c.cmd.LoadDescription()
+				title = c.cmd.Title
+			}
+			_, _ = fmt.Fprintf(config.ErrOut, "  %s%s    %s\n", name, strings.Repeat(" ", padLen-len(name)), title)
+			list(c, depth+1)
+		}
+	}
+	list(root, 0)
+}
+
+// subCommandListName generates the name to display for a sub-command, including aliases.
+//
+func subCommandListName(n *subCommandNode) string {
+	if n.cmd == nil || len(n.cmd.Aliases) == 0 {
+		return n.name
+	}
+	return fmt.Sprintf("%s (%s)", n.name, strings.Join(n.cmd.Aliases, ", "))
+}
+
+// isVisible returns true if the command can be listed / invoked by name.
+//
+func isVisible(cmd *config.Command, showHidden bool) bool {
+	return !cmd.Flags.Private() && (!cmd.Flags.Hidden() || showHidden)
+}
+
+// commandNames returns the normalized name and aliases of the command.
+//
+func commandNames(cmd *config.Command) []string {
+	names := []string{strings.ToLower(cmd.Name)}
+	for _, alias := range cmd.Aliases {
+		names = append(names, strings.ToLower(alias))
+	}
+	return names
+}
+
+// ResolveCommandPrefix resolves a (normalized) name to the unique command whose name, or alias, it is a prefix of.
+// Only applies if config.PrefixMatch is enabled, and the name is neither a command nor the parent of sub-commands.
+// Returns the normalized name of the command if the match is unique, else the unchanged name,
+// along with all matching commands, which the caller should report as ambiguous if 2+.
+//
+func ResolveCommandPrefix(name string, showHidden bool) (string, []*config.Command) {
+	if !config.PrefixMatch {
+		return name, nil
+	}
+	if _, ok := config.CommandMap[name]; ok || HasSubCommands(name, showHidden) {
+		return name, nil
+	}
+	var matches []*config.Command
+	for _, cmd := range config.CommandList {
+		if !isVisible(cmd, showHidden) {
+			continue
+		}
+		for _, cmdName := range commandNames(cmd) {
+			if strings.HasPrefix(cmdName, name) {
+				matches = append(matches, cmd)
+				break
+			}
+		}
+	}
+	if len(matches) == 1 {
+		return strings.ToLower(matches[0].Name), matches
+	}
+	return name, matches
+}
+
+// SuggestCommands returns commands with a name, or alias, similar to the (normalized) name.
+// Commands are similar if the name is a prefix, or is within a small edit distance.
+// Returns the closest matches first, else in the order they are registered.
+//
+func SuggestCommands(name string, showHidden bool) []*config.Command {
+	maxDistance := 1 + len(name)/4
+	var suggestions []*config.Command
+	distances := make(map[*config.Command]int)
+	for _, cmd := range config.CommandList {
+		if !isVisible(cmd, showHidden) {
+			continue
+		}
+		best := -1
+		for _, cmdName := range commandNames(cmd) {
+			d := util.EditDistance(name, cmdName)
+			if strings.HasPrefix(cmdName, name) {
+				d = 0
+			}
+			if best < 0 || d < best {
+				best = d
+			}
+		}
+		if best <= maxDistance {
+			suggestions = append(suggestions, cmd)
+			distances[cmd] = best
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return distances[suggestions[i]] < distances[suggestions[j]]
+	})
+	return suggestions
+}
+
+// ListCommandSuggestions prints the specified commands as suggestions.
+//
+func ListCommandSuggestions(cmds []*config.Command) {
+	padLen := 0
+	for _, cmd := range cmds {
+		if len(listName(cmd)) > padLen {
+			padLen = len(listName(cmd))
+		}
+	}
+	listCommandSection("Did you mean", cmds, padLen)
+}
+
+// ListCommandsOrSuggestions prints suggestions for a command that was not found,
+// falling back to the full list of commands if there are none.
+//
+func ListCommandsOrSuggestions(name string, showHidden bool) {
+	if suggestions := SuggestCommands(name, showHidden); len(suggestions) > 0 {
+		ListCommandSuggestions(suggestions)
+	} else {
+		ListCommands()
+	}
+}
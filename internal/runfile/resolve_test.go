@@ -0,0 +1,143 @@
+package runfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tekwizely/run/internal/config"
+)
+
+// setCommands registers the named commands, restoring the previous commands when the test completes.
+// Names with a leading '.' are registered as hidden, names with a leading '!' as private.
+//
+func setCommands(t *testing.T, names ...string) {
+	commandMap, commandList, prefixMatch := config.CommandMap, config.CommandList, config.PrefixMatch
+	t.Cleanup(func() {
+		config.CommandMap, config.CommandList, config.PrefixMatch = commandMap, commandList, prefixMatch
+	})
+	config.CommandMap = map[string]*config.Command{}
+	config.CommandList = nil
+	for _, name := range names {
+		cmd := &config.Command{Name: name}
+		if strings.HasPrefix(name, ".") {
+			cmd.Name, cmd.Flags = name[1:], config.FlagHidden
+		} else if strings.HasPrefix(name, "!") {
+			cmd.Name, cmd.Flags = name[1:], config.FlagPrivate
+		}
+		config.CommandMap[cmd.Name] = cmd
+		config.CommandList = append(config.CommandList, cmd)
+	}
+}
+
+func TestResolveCommandPath(t *testing.T) {
+	tests := []struct {
+		prefixMatch bool
+		showHidden  bool
+		args        []string
+		wantName    string
+		wantArgs    []string
+	}{
+		{false, false, []string{"db", "migrate", "up", "--force"}, "db/migrate/up", []string{"--force"}},
+		{false, false, []string{"db", "migrate"}, "db/migrate", []string{}},
+		{false, false, []string{"db", "seed", "x"}, "db/seed", []string{"x"}},
+		{false, false, []string{"db", "nope"}, "db", []string{"nope"}},
+		{false, false, []string{"db", "mi", "up"}, "db", []string{"mi", "up"}},
+		{true, false, []string{"db", "mi", "up"}, "db/migrate/up", []string{}},
+		{true, false, []string{"d", "s"}, "d", []string{"s"}}, // 'd' is ambiguous: db, deploy
+		{true, false, []string{"dep"}, "dep", []string{}},     // Left to ResolveCommandPrefix
+		{false, false, []string{"ops", "secret"}, "ops", []string{"secret"}},
+		{false, true, []string{"ops", "secret"}, "ops/secret", []string{}},
+		{true, true, []string{"o", "s"}, "ops/secret", []string{}},
+		{false, true, []string{"internal", "tool"}, "internal", []string{"tool"}},
+	}
+	for _, test := range tests {
+		setCommands(t, "db/migrate", "db/migrate/up", "db/seed", "deploy", ".ops/secret", "!internal/tool")
+		config.PrefixMatch = test.prefixMatch
+		gotName, gotArgs := ResolveCommandPath(test.args[0], test.args[1:], test.showHidden)
+		if gotName != test.wantName || !reflect.DeepEqual(gotArgs, test.wantArgs) {
+			t.Errorf("ResolveCommandPath(%q, prefixMatch=%v, showHidden=%v) = %q, %q, want %q, %q", test.args, test.prefixMatch, test.showHidden, gotName, gotArgs, test.wantName, test.wantArgs)
+		}
+	}
+}
+
+func TestHasSubCommands(t *testing.T) {
+	setCommands(t, "db/seed", ".ops/secret", "!internal/tool")
+	tests := []struct {
+		cmdPath    string
+		showHidden bool
+		want       bool
+	}{
+		{"db", false, true},
+		{"ops", false, false},
+		{"ops", true, true},
+		{"internal", false, false},
+		{"internal", true, false},
+		{"db/seed", false, false},
+	}
+	for _, test := range tests {
+		if got := HasSubCommands(test.cmdPath, test.showHidden); got != test.want {
+			t.Errorf("HasSubCommands(%q, %v) = %v, want %v", test.cmdPath, test.showHidden, got, test.want)
+		}
+	}
+}
+
+// commandNameList returns the names of the commands.
+//
+func commandNameList(cmds []*config.Command) []string {
+	names := []string{}
+	for _, cmd := range cmds {
+		names = append(names, cmd.Name)
+	}
+	return names
+}
+
+func TestResolveCommandPrefix(t *testing.T) {
+	tests := []struct {
+		prefixMatch bool
+		showHidden  bool
+		name        string
+		wantName    string
+		wantMatches []string
+	}{
+		{false, false, "dep", "dep", []string{}},
+		{true, false, "dep", "deploy", []string{"deploy"}},
+		{true, false, "de", "de", []string{"deploy", "destroy"}},
+		{true, false, "deploy", "deploy", []string{}},
+		{true, false, "bu", "build", []string{"build"}},
+		{true, false, "sec", "sec", []string{}},
+		{true, true, "sec", "secret", []string{"secret"}},
+		{true, false, "nope", "nope", []string{}},
+	}
+	for _, test := range tests {
+		setCommands(t, "build", "deploy", "destroy", ".secret")
+		config.PrefixMatch = test.prefixMatch
+		gotName, gotMatches := ResolveCommandPrefix(test.name, test.showHidden)
+		if gotName != test.wantName || !reflect.DeepEqual(commandNameList(gotMatches), test.wantMatches) {
+			t.Errorf("ResolveCommandPrefix(%q, prefixMatch=%v, showHidden=%v) = %q, %q, want %q, %q", test.name, test.prefixMatch, test.showHidden, gotName, commandNameList(gotMatches), test.wantName, test.wantMatches)
+		}
+	}
+}
+
+func TestSuggestCommands(t *testing.T) {
+	setCommands(t, "build", "deploy", "destroy", "test", ".secret", "!private")
+	tests := []struct {
+		name       string
+		showHidden bool
+		want       []string
+	}{
+		{"biuld", false, []string{"build"}},
+		{"deplyo", false, []string{"deploy"}},
+		{"de", false, []string{"deploy", "destroy"}},
+		{"tset", false, []string{"test"}},
+		{"secert", false, []string{}},
+		{"secert", true, []string{"secret"}},
+		{"privat", true, []string{}},
+		{"zzzzzz", false, []string{}},
+	}
+	for _, test := range tests {
+		if got := commandNameList(SuggestCommands(test.name, test.showHidden)); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("SuggestCommands(%q, %v) = %q, want %q", test.name, test.showHidden, got, test.want)
+		}
+	}
+}
@@ -1,45 +1,198 @@
 package runfile
 
 import (
+	"fmt"
 	"strings"
 
 	"github.com/tekwizely/run/internal/config"
+	"github.com/tekwizely/run/internal/util"
 )
 
 // CmdProvider allows us to construct commands
 // multiple times in different contexts.
 type CmdProvider interface {
 	GetCmd(r *Runfile) *RunCmd
+	DescribeCmd(r *Runfile) *RunCmd
 	GetCmdEnv(r *Runfile, env map[string]string) *RunCmd
+	GetCmdInfo(r *Runfile) *CmdInfo
+}
+
+// CmdInfo captures the metadata needed to register a command.
+// Unlike RunCmd, it is available without evaluating any of the command's values.
+//
+type CmdInfo struct {
+	Flags   config.CmdFlags
+	Name    string // Includes namespace, if any
+	Runfile string
+	Line    int
+	Shell   string
+	Aliases []string // Include namespace, if any
+	Default bool
+	HasDesc bool
+}
+
+// Hook captures a BEFORE.ALL / AFTER.ALL hook.
+//
+type Hook struct {
+	Command   string
+	Namespace string   // Namespace of the runfile defining the hook, used to resolve Command
+	Patterns  []string // Lower-cased; Empty = match all commands
+	Runfile   string
+	Line      int
+}
+
+// Matches returns true if the hook applies to the named (normalized) command.
+// Hooks defined within a namespace only apply to commands in that namespace,
+// with patterns matched against the command name relative to the namespace.
+// Patterns are shell globs, with '*' also matching the '/' within sub-command names.
+//
+func (h *Hook) Matches(cmdName string) bool {
+	if len(h.Namespace) > 0 {
+		prefix := strings.ToLower(h.Namespace) + ":"
+		if !strings.HasPrefix(cmdName, prefix) {
+			return false
+		}
+		cmdName = strings.TrimPrefix(cmdName, prefix)
+	}
+	if len(h.Patterns) == 0 {
+		return true
+	}
+	for _, pattern := range h.Patterns {
+		if re, err := util.CompileGlob(pattern); err == nil && re.MatchString(cmdName) {
+			return true
+		}
+	}
+	return false
+}
+
+// OptProvider allows us to construct command options
+// multiple times in different contexts.
+type OptProvider interface {
+	Apply(c *RunCmd) *RunCmdOpt
+}
+
+// OptionSet captures a named set of options, shared across commands.
+//
+type OptionSet struct {
+	Name      string
+	Opts      []OptProvider
+	Namespace string // Namespace of the including runfile(s)
+	Runfile   string
+	Line      int
+}
+
+// GlobalOpt captures an option accepted by all commands within its namespace (or all commands, if top-level).
+//
+type GlobalOpt struct {
+	Opt       OptProvider
+	Name      string
+	Short     rune
+	Long      string
+	Namespace string // Namespace of the including runfile(s)
+	Runfile   string
+	Line      int
 }
 
 // Runfile stores the processed file, ready to run.
 //
 type Runfile struct {
-	Scope *Scope
-	Cmds  []CmdProvider
+	Scope      *Scope
+	Cmds       []CmdProvider
+	OptionSets map[string]*OptionSet // Key = lowercase [namespace:]name of set
+	GlobalOpts []*GlobalOpt
+	BeforeAll  []*Hook
+	AfterAll   []*Hook
 }
 
 // NewRunfile is a convenience method.
 //
 func NewRunfile() *Runfile {
 	return &Runfile{
-		Scope: NewScope(),
-		Cmds:  []CmdProvider{},
+		Scope:      NewScope(),
+		Cmds:       []CmdProvider{},
+		OptionSets: map[string]*OptionSet{},
 	}
 }
 
+// LookupOptionSet finds an option set referenced from within the specified namespace.
+// Like LookupCommand, the name is resolved against the namespace first, then each parent namespace,
+// and finally the top-level (un-namespaced) option sets.
+//
+func (r *Runfile) LookupOptionSet(namespace string, name string) (*OptionSet, bool) {
+	namespace = strings.ToLower(namespace) // Normalize
+	name = strings.ToLower(name)
+	for len(namespace) > 0 {
+		if set, ok := r.OptionSets[namespace+":"+name]; ok {
+			return set, true
+		}
+		if i := strings.LastIndex(namespace, ":"); i >= 0 {
+			namespace = namespace[:i]
+		} else {
+			namespace = ""
+		}
+	}
+	set, ok := r.OptionSets[name]
+	return set, ok
+}
+
 // RunCmdOpt captures an OPTION.
 //
 type RunCmdOpt struct {
 	Name       string
 	Required   bool
 	HasDefault bool
-	Default    string
+	Default    *LazyValue
 	Short      rune
 	Long       string
 	Example    string
-	Desc       string
+	Desc       *LazyValue // Only evaluated when showing help
+}
+
+// SuperCommand is the command name used to RUN the command overridden by the current command.
+//
+const SuperCommand = "^"
+
+// CheckCmdOptConflicts checks that no two options share a variable name, short flag or long flag.
+//
+func CheckCmdOptConflicts(opts []*RunCmdOpt) error {
+	var (
+		names  = make(map[string]*RunCmdOpt)
+		shorts = make(map[rune]*RunCmdOpt)
+		longs  = make(map[string]*RunCmdOpt)
+	)
+	for _, opt := range opts {
+		if existing, ok := names[opt.Name]; ok {
+			return fmt.Errorf("option %s defined multiple times", existing.Name)
+		}
+		names[opt.Name] = opt
+		if opt.Short != 0 {
+			if existing, ok := shorts[opt.Short]; ok {
+				return fmt.Errorf("option %s conflicts with option %s: -%c", opt.Name, existing.Name, opt.Short)
+			}
+			shorts[opt.Short] = opt
+		}
+		if len(opt.Long) > 0 {
+			long := strings.ToLower(opt.Long) // Long flags are case-insensitive
+			if existing, ok := longs[long]; ok {
+				return fmt.Errorf("option %s conflicts with option %s: --%s", opt.Name, existing.Name, long)
+			}
+			longs[long] = opt
+		}
+	}
+	return nil
+}
+
+// ShadowsCmdOpt returns true if any of the options share a variable name, short flag or long flag with opt.
+//
+func ShadowsCmdOpt(opts []*RunCmdOpt, opt *RunCmdOpt) bool {
+	for _, o := range opts {
+		if o.Name == opt.Name ||
+			(opt.Short != 0 && o.Short == opt.Short) ||
+			(len(opt.Long) > 0 && strings.EqualFold(o.Long, opt.Long)) {
+			return true
+		}
+	}
+	return false
 }
 
 // RunCmdRun captures a command config RUN invocation.
@@ -47,38 +200,88 @@ type RunCmdOpt struct {
 //
 type RunCmdRun struct {
 	Command string
-	Args    []string
+	Args    []*LazyValue
+	Each    bool // RUN.EACH - Invoke once per combination of list items
+}
+
+// Invocations returns the arg lists to invoke the command with.
+// List args are spliced into the args, unless Each is set,
+// in which case the command is invoked once per combination of list items.
+//
+func (r *RunCmdRun) Invocations() [][]string {
+	if !r.Each {
+		return [][]string{LazyValues(r.Args)}
+	}
+	invocations := [][]string{{}}
+	for _, arg := range r.Args {
+		values := []string{arg.Get()}
+		if arg.IsList() {
+			values = arg.Items()
+		}
+		next := make([][]string, 0, len(invocations)*len(values))
+		for _, invocation := range invocations {
+			for _, value := range values {
+				args := append(append([]string{}, invocation...), value)
+				next = append(next, args)
+			}
+		}
+		invocations = next
+	}
+	return invocations
+}
+
+// RunCmdForeach captures a command config FOREACH matrix variable.
+//
+type RunCmdForeach struct {
+	Name   string
+	Values *LazyValue // List
 }
 
 // RunCmdConfig captures the configuration for a command.
 //
 type RunCmdConfig struct {
 	Shell      string
-	Desc       []string
-	Usages     []string
+	Aliases    []string
+	Group      *LazyValue
+	Default    bool       // Run when no command specified
+	Desc       *LazyValue // List - Only evaluated when listing commands or showing help
+	Usages     *LazyValue // List
 	Opts       []*RunCmdOpt
+	GlobalOpts []*RunCmdOpt // Global options not shadowed by Opts
 	EnvRuns    []*RunCmdRun
 	BeforeRuns []*RunCmdRun
 	AfterRuns  []*RunCmdRun
+	Foreach    []*RunCmdForeach // Script is executed once per combination of values
+	Parallel   bool             // FOREACH.PARALLEL - Execute FOREACH combinations in parallel
 }
 
 // RunCmd captures a command.
 //
 type RunCmd struct {
-	Flags   config.CmdFlags
-	Name    string
-	Config  *RunCmdConfig
-	Scope   *Scope
-	Script  []string
-	Runfile string
-	Line    int
+	Flags     config.CmdFlags
+	Name      string // Includes namespace, if any
+	Namespace string
+	Config    *RunCmdConfig
+	Scope     *Scope
+	Script    []string
+	Runfile   string
+	Line      int
+}
+
+// NamespacedName prefixes the name with the namespace, if present.
+//
+func NamespacedName(namespace string, name string) string {
+	if len(namespace) > 0 {
+		return namespace + ":" + name
+	}
+	return name
 }
 
 // Title fetches the first line of the description as the command title.
 //
 func (c *RunCmd) Title() string {
-	if len(c.Config.Desc) > 0 {
-		return c.Config.Desc[0]
+	if desc := c.Config.Desc.Items(); len(desc) > 0 {
+		return desc[0]
 	}
 	return ""
 }
@@ -96,7 +299,7 @@ func (c *RunCmd) Shell() string {
 			// Global shell configured?
 			//
 			var ok bool
-			if shell, ok = c.Scope.Attrs[".SHELL"]; !ok || len(shell) == 0 {
+			if shell, ok = c.Scope.GetAttr(".SHELL"); !ok || len(shell) == 0 {
 				// Use default
 				//
 				shell = config.DefaultShell
@@ -110,5 +313,13 @@ func (c *RunCmd) Shell() string {
 // Returns false if there isn't any custom information to display.
 //
 func (c *RunCmd) EnableHelp() bool {
-	return len(c.Config.Desc) > 0 || len(c.Config.Usages) > 0 || len(c.Config.Opts) > 0
+	return len(c.Config.Desc.Items()) > 0 || len(c.Config.Usages.Items()) > 0 || len(c.Config.Opts) > 0 || len(c.Config.GlobalOpts) > 0
+}
+
+// AllOpts returns the command's own options, followed by any global options.
+//
+func (c *RunCmd) AllOpts() []*RunCmdOpt {
+	opts := make([]*RunCmdOpt, 0, len(c.Config.Opts)+len(c.Config.GlobalOpts))
+	opts = append(opts, c.Config.Opts...)
+	return append(opts, c.Config.GlobalOpts...)
 }
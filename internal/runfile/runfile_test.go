@@ -0,0 +1,31 @@
+package runfile
+
+import (
+	"testing"
+)
+
+func TestHookMatches(t *testing.T) {
+	tests := []struct {
+		hook    Hook
+		cmdName string
+		want    bool
+	}{
+		{Hook{}, "build", true},
+		{Hook{Patterns: []string{"build*"}}, "build", true},
+		{Hook{Patterns: []string{"build*"}}, "build-all", true},
+		{Hook{Patterns: []string{"build*"}}, "test", false},
+		{Hook{Patterns: []string{"test", "lint"}}, "lint", true},
+		{Hook{Patterns: []string{"db*"}}, "db/migrate/up", true},
+		{Hook{Patterns: []string{"db/*"}}, "db/migrate/up", true},
+		{Hook{Patterns: []string{"db/?eed"}}, "db/seed", true},
+		{Hook{Patterns: []string{"db"}}, "db/seed", false},
+		{Hook{Namespace: "Api", Patterns: []string{"build"}}, "api:build", true},
+		{Hook{Namespace: "api", Patterns: []string{"build"}}, "web:build", false},
+		{Hook{Namespace: "api"}, "build", false},
+	}
+	for _, test := range tests {
+		if got := test.hook.Matches(test.cmdName); got != test.want {
+			t.Errorf("Hook{Namespace: %q, Patterns: %q}.Matches(%q) = %v, want %v", test.hook.Namespace, test.hook.Patterns, test.cmdName, got, test.want)
+		}
+	}
+}
@@ -1,14 +1,22 @@
 package runfile
 
-import "os"
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/tekwizely/run/internal/config"
+	"github.com/tekwizely/run/internal/util"
+)
 
 // Assert captures an assertion for a runfile.
 //
 type Assert struct {
 	Runfile string
 	Line    int
-	Test    string
-	Message string
+	Test    *LazyValue
+	Message *LazyValue
 }
 
 // VarExport captures a variable export.
@@ -25,13 +33,17 @@ type AttrExport struct {
 }
 
 // Scope isolates attrs, vars and exports
+// Attrs, Vars and Readonly only hold the entries assigned since the last Snapshot,
+// earlier entries are found via the (frozen) parent scope.
 //
 type Scope struct {
-	Attrs       map[string]string // All keys uppercase. Keys include leading '.'
-	Vars        map[string]string // Variables
-	VarExports  []*VarExport      // Exported variables
-	AttrExports []*AttrExport     // Exported attributes
-	Asserts     []*Assert         // Assertions
+	Attrs       map[string]string     // All keys uppercase. Keys include leading '.'
+	Vars        map[string]*LazyValue // Variables - Evaluated on first use
+	Readonly    map[string]string     // Readonly variables - Values are where the variable was defined
+	VarExports  []*VarExport          // Exported variables
+	AttrExports []*AttrExport         // Exported attributes
+	Asserts     []*Assert             // Assertions
+	parent      *Scope                // Frozen - Never modified once assigned as a parent
 }
 
 // NewScope is a convenience method
@@ -39,7 +51,8 @@ type Scope struct {
 func NewScope() *Scope {
 	return &Scope{
 		Attrs:       map[string]string{},
-		Vars:        map[string]string{},
+		Vars:        map[string]*LazyValue{},
+		Readonly:    map[string]string{},
 		VarExports:  []*VarExport{},
 		AttrExports: []*AttrExport{},
 		Asserts:     []*Assert{},
@@ -55,8 +68,12 @@ func (s *Scope) GetEnv(key string) (string, bool) {
 // GetAttr fetches an attr
 //
 func (s *Scope) GetAttr(key string) (string, bool) {
-	val, ok := s.Attrs[key]
-	return val, ok
+	for ; s != nil; s = s.parent {
+		if val, ok := s.Attrs[key]; ok {
+			return val, true
+		}
+	}
+	return "", false
 }
 
 // PutAttr sets an attr
@@ -80,16 +97,163 @@ func (s *Scope) GetAttrExports() []*AttrExport {
 // GetVar fetches a variable
 //
 func (s *Scope) GetVar(key string) (string, bool) {
-	val, ok := s.Vars[key]
-	return val, ok
+	if val, ok := s.GetLazyVar(key); ok {
+		return val.Get(), true
+	}
+	return "", false
+}
+
+// GetList fetches a variable as a list.
+// Non-list values are returned as a single-item list, or an empty list if the value is empty.
+//
+func (s *Scope) GetList(key string) ([]string, bool) {
+	if val, ok := s.GetLazyVar(key); ok {
+		return val.Items(), true
+	}
+	return nil, false
+}
+
+// GetLazyVar fetches a variable without evaluating it.
+//
+func (s *Scope) GetLazyVar(key string) (*LazyValue, bool) {
+	for ; s != nil; s = s.parent {
+		if val, ok := s.Vars[key]; ok {
+			return val, true
+		}
+	}
+	return nil, false
+}
+
+// VarNames returns the names of all variables defined in the scope.
+//
+func (s *Scope) VarNames() []string {
+	seen := make(map[string]struct{})
+	var names []string
+	for ; s != nil; s = s.parent {
+		for key := range s.Vars {
+			if _, ok := seen[key]; !ok {
+				seen[key] = struct{}{}
+				names = append(names, key)
+			}
+		}
+	}
+	return names
+}
+
+// AddVarToEnv adds the variable to the env, returning false if the variable is not defined.
+// List values are joined using the .LIST.SEP attribute (default ' '),
+// and are also added as numbered variables (NAME_0 .. NAME_<n-1>, NAME_COUNT) if the .LIST.NUMBERED attribute is enabled.
+//
+func (s *Scope) AddVarToEnv(env map[string]string, key string) bool {
+	val, ok := s.GetLazyVar(key)
+	if !ok {
+		return false
+	}
+	if !val.IsList() {
+		env[key] = val.Get()
+		return true
+	}
+	items := val.Items()
+	sep, ok := s.GetAttr(".LIST.SEP")
+	if !ok {
+		sep = " "
+	}
+	env[key] = strings.Join(items, sep)
+	if numbered, _ := s.GetAttr(".LIST.NUMBERED"); util.IsTrue(numbered) {
+		for i, item := range items {
+			env[fmt.Sprintf("%s_%d", key, i)] = item
+		}
+		env[key+"_COUNT"] = strconv.Itoa(len(items))
+	}
+	return true
 }
 
 // PutVar sets a variable
 //
 func (s *Scope) PutVar(key, value string) {
+	s.PutLazyVar(key, NewValue(value))
+}
+
+// PutLazyVar sets a variable, deferring evaluation until the value is needed.
+// Variables overridden on the command line retain their override value.
+//
+func (s *Scope) PutLazyVar(key string, value *LazyValue) {
+	s.CheckWritable(key, config.CurrentRunfile)
+	if override, ok := config.VarOverrides[key]; ok {
+		value = NewValue(override)
+	}
 	s.Vars[key] = value
 }
 
+// PutReadonlyVar sets a variable, marking it as readonly.
+// Where describes the location of the assignment, for use in error messages.
+// Readonly variables cannot be overridden from the command line.
+//
+func (s *Scope) PutReadonlyVar(key string, value *LazyValue, where string) {
+	if _, ok := config.VarOverrides[key]; ok {
+		panic(fmt.Errorf("%s: readonly variable '%s' cannot be overridden from the command line", where, key))
+	}
+	s.PutLazyVar(key, value)
+	s.Readonly[key] = where
+}
+
+// ReadonlyError reports an attempt to assign a readonly variable.
+//
+type ReadonlyError struct {
+	Name  string
+	Where string // Where the variable was defined
+	At    string // Where the assignment was attempted
+}
+
+// Error implements the error interface.
+//
+func (e *ReadonlyError) Error() string {
+	return fmt.Sprintf("%s: cannot assign readonly variable '%s' (defined at %s)", e.At, e.Name, e.Where)
+}
+
+// CheckWritable panics with a *ReadonlyError if the variable is readonly.
+// At describes the location of the assignment, for use in error messages.
+//
+func (s *Scope) CheckWritable(key string, at string) {
+	if where, ok := s.readonlyWhere(key); ok {
+		panic(&ReadonlyError{Name: key, Where: where, At: at})
+	}
+}
+
+// IsReadonly returns true if the variable is readonly.
+//
+func (s *Scope) IsReadonly(key string) bool {
+	_, ok := s.readonlyWhere(key)
+	return ok
+}
+
+// readonlyWhere returns where the readonly variable was defined, if the variable is readonly.
+//
+func (s *Scope) readonlyWhere(key string) (string, bool) {
+	for ; s != nil; s = s.parent {
+		if where, ok := s.Readonly[key]; ok {
+			return where, true
+		}
+	}
+	return "", false
+}
+
+// Snapshot returns a view of the scope, used to evaluate lazy values as of the time they were defined.
+// The entries assigned so far are frozen into a parent scope shared by the scope and the snapshot,
+// so taking a snapshot does not copy the scope.
+// Variable values are shared with the original scope, so are still only evaluated once.
+// Exports and asserts are not included.
+//
+func (s *Scope) Snapshot() *Scope {
+	if len(s.Attrs) > 0 || len(s.Vars) > 0 || len(s.Readonly) > 0 {
+		s.parent = &Scope{Attrs: s.Attrs, Vars: s.Vars, Readonly: s.Readonly, parent: s.parent}
+		s.Attrs, s.Vars, s.Readonly = map[string]string{}, map[string]*LazyValue{}, map[string]string{}
+	}
+	snapshot := NewScope()
+	snapshot.parent = s.parent
+	return snapshot
+}
+
 // ExportVar adds a variable name to the list of exports
 //
 func (s *Scope) ExportVar(name string) {
@@ -0,0 +1,63 @@
+package runfile
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestScopeSnapshot(t *testing.T) {
+	s := NewScope()
+	s.PutVar("A", "1")
+	s.PutAttr(".SHELL", "bash")
+	s.PutReadonlyVar("R", NewValue("r"), "Runfile:1")
+	snapshot := s.Snapshot()
+	// Later assignments are not visible to the snapshot
+	//
+	s.PutVar("A", "2")
+	s.PutVar("B", "3")
+	s.PutAttr(".SHELL", "zsh")
+	if val, _ := snapshot.GetVar("A"); val != "1" {
+		t.Errorf("snapshot A = %q, want %q", val, "1")
+	}
+	if _, ok := snapshot.GetVar("B"); ok {
+		t.Errorf("snapshot B defined, want undefined")
+	}
+	if val, _ := snapshot.GetAttr(".SHELL"); val != "bash" {
+		t.Errorf("snapshot .SHELL = %q, want %q", val, "bash")
+	}
+	if !snapshot.IsReadonly("R") {
+		t.Errorf("snapshot R not readonly")
+	}
+	// Assignments to the snapshot are not visible to the scope
+	//
+	snapshot.PutVar("C", "4")
+	if _, ok := s.GetVar("C"); ok {
+		t.Errorf("scope C defined, want undefined")
+	}
+	if val, _ := s.GetVar("A"); val != "2" {
+		t.Errorf("scope A = %q, want %q", val, "2")
+	}
+	// Snapshots of snapshots see the same values
+	//
+	if val, _ := s.Snapshot().GetVar("A"); val != "2" {
+		t.Errorf("second snapshot A = %q, want %q", val, "2")
+	}
+	names := s.VarNames()
+	sort.Strings(names)
+	if got := strings.Join(names, ","); got != "A,B,R" {
+		t.Errorf("VarNames() = %q, want %q", got, "A,B,R")
+	}
+}
+
+func TestScopeSnapshotReadonly(t *testing.T) {
+	s := NewScope()
+	s.PutReadonlyVar("R", NewValue("r"), "Runfile:1")
+	s.Snapshot()
+	defer func() {
+		if r := recover(); r == nil {
+			t.Errorf("expected readonly assignment to panic")
+		}
+	}()
+	s.PutVar("R", "x")
+}
@@ -0,0 +1,102 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JobFunc executes the job at index i, writing its output to stdout and stderr.
+// Returns the exit code of the job.
+//
+type JobFunc func(i int, stdout io.Writer, stderr io.Writer) int
+
+// JobResult captures the outcome of a job, for reporting.
+//
+type JobResult struct {
+	Name     string
+	ExitCode int
+	Skipped  string // Reason the job was skipped, if any
+}
+
+// ParseJobs parses the value of a jobs attribute (i.e. .FOREACH.JOBS), the maximum number of jobs to execute in parallel.
+// Defaults to the number of CPUs if the value is empty.
+//
+func ParseJobs(attr string, value string) (int, error) {
+	value = strings.TrimSpace(value)
+	if len(value) == 0 {
+		return runtime.NumCPU(), nil
+	}
+	jobs, err := strconv.Atoi(value)
+	if err != nil || jobs < 1 {
+		return 0, fmt.Errorf("%s: expecting a positive number, got '%s'", attr, value)
+	}
+	return jobs, nil
+}
+
+// RunParallel executes n jobs in parallel, with at most maxJobs executing at a time.
+// Jobs cannot share stdin, so are expected to execute without it.
+// Output is buffered, and written to stdout and stderr once the job completes,
+// to avoid interleaving output across jobs.
+// header is invoked before each job's output is written, allowing the caller to introduce it.
+// Panics would otherwise escape the caller's recover, so are reported as a failed job (exit code 1).
+// Returns the exit code of each job.
+//
+func RunParallel(n int, maxJobs int, run JobFunc, header func(i int, exitCode int), stdout io.Writer, stderr io.Writer) []int {
+	var (
+		wg    sync.WaitGroup
+		mutex sync.Mutex
+	)
+	exitCodes := make([]int, n)
+	slots := make(chan struct{}, maxJobs)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			jobOut := &bytes.Buffer{}
+			jobErr := &bytes.Buffer{}
+			defer func() {
+				if r := recover(); r != nil {
+					exitCodes[i] = 1
+					_, _ = fmt.Fprintf(jobErr, "%s\n", r)
+				}
+				mutex.Lock()
+				defer mutex.Unlock()
+				header(i, exitCodes[i])
+				_, _ = io.Copy(stdout, jobOut)
+				_, _ = io.Copy(stderr, jobErr)
+			}()
+			exitCodes[i] = run(i, jobOut, jobErr)
+		}(i)
+	}
+	wg.Wait()
+	return exitCodes
+}
+
+// ReportJobResults writes a PASS / FAIL / SKIP line for each job, following the title.
+// Returns the exit code of the first failed job, if any.
+//
+func ReportJobResults(out io.Writer, title string, results []JobResult) int {
+	exitCode := 0
+	_, _ = fmt.Fprintln(out, title)
+	for _, result := range results {
+		switch {
+		case len(result.Skipped) > 0:
+			_, _ = fmt.Fprintf(out, "  SKIP  %s (%s)\n", result.Name, result.Skipped)
+		case result.ExitCode == 0:
+			_, _ = fmt.Fprintf(out, "  PASS  %s\n", result.Name)
+		default:
+			_, _ = fmt.Fprintf(out, "  FAIL  %s (exit code %d)\n", result.Name, result.ExitCode)
+			if exitCode == 0 {
+				exitCode = result.ExitCode
+			}
+		}
+	}
+	return exitCode
+}
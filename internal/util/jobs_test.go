@@ -0,0 +1,82 @@
+package util
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"runtime"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseJobs(t *testing.T) {
+	if jobs, err := ParseJobs(".JOBS", " "); err != nil || jobs != runtime.NumCPU() {
+		t.Errorf("default: got %d, %v, want %d", jobs, err, runtime.NumCPU())
+	}
+	if jobs, err := ParseJobs(".JOBS", " 3 "); err != nil || jobs != 3 {
+		t.Errorf("3: got %d, %v, want 3", jobs, err)
+	}
+	for _, value := range []string{"0", "-1", "x"} {
+		want := fmt.Sprintf(".JOBS: expecting a positive number, got '%s'", value)
+		if _, err := ParseJobs(".JOBS", value); err == nil || err.Error() != want {
+			t.Errorf("%s: got %v, want %q", value, err, want)
+		}
+	}
+}
+
+func TestRunParallel(t *testing.T) {
+	var running, maxRunning int32
+	out := &bytes.Buffer{}
+	exitCodes := RunParallel(4, 2, func(i int, stdout io.Writer, stderr io.Writer) int {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			m := atomic.LoadInt32(&maxRunning)
+			if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		if i == 3 {
+			panic("boom")
+		}
+		_, _ = fmt.Fprintf(stdout, "job %d\n", i)
+		return i
+	}, func(i int, exitCode int) {
+		_, _ = fmt.Fprintf(out, "==> %d (%d)\n", i, exitCode)
+	}, out, out)
+	if want := []int{0, 1, 2, 1}; !reflect.DeepEqual(exitCodes, want) {
+		t.Errorf("exit codes = %v, want %v", exitCodes, want)
+	}
+	if maxRunning > 2 {
+		t.Errorf("max running = %d, want <= 2", maxRunning)
+	}
+	for i := 0; i < 3; i++ {
+		want := fmt.Sprintf("==> %d (%d)\njob %d\n", i, i, i)
+		if !bytes.Contains(out.Bytes(), []byte(want)) {
+			t.Errorf("output %q missing %q", out.String(), want)
+		}
+	}
+	if want := "==> 3 (1)\nboom\n"; !bytes.Contains(out.Bytes(), []byte(want)) {
+		t.Errorf("output %q missing %q", out.String(), want)
+	}
+}
+
+func TestReportJobResults(t *testing.T) {
+	out := &bytes.Buffer{}
+	exitCode := ReportJobResults(out, "results:", []JobResult{
+		{Name: "a"},
+		{Name: "b", ExitCode: 3},
+		{Name: "c", ExitCode: 2, Skipped: "not defined"},
+		{Name: "d", ExitCode: 4},
+	})
+	if exitCode != 3 {
+		t.Errorf("exit code = %d, want 3", exitCode)
+	}
+	want := "results:\n  PASS  a\n  FAIL  b (exit code 3)\n  SKIP  c (not defined)\n  FAIL  d (exit code 4)\n"
+	if got := out.String(); got != want {
+		t.Errorf("output = %q, want %q", got, want)
+	}
+}
@@ -1,13 +1,17 @@
 package util
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"io/fs"
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"regexp"
 	"strings"
+
+	"github.com/subosito/gotenv"
 )
 
 // DefaultIfEmpty returns default string of src string is empty.
@@ -26,6 +30,83 @@ func GetEnvOrDefault(key string, def string) string {
 	return DefaultIfEmpty(os.Getenv(key), def)
 }
 
+// IsTrue returns true if the value represents an enabled boolean flag,
+// i.e. '1', 'true', 'yes', 'on' (case-insensitive).
+//
+func IsTrue(value string) bool {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "1", "true", "yes", "on":
+		return true
+	}
+	return false
+}
+
+// ParseDotEnvRefs parses .env content, leaving variable references un-expanded.
+// Values can then be expanded via os.Expand, where '$$' expands to a literal '$'.
+// '$' within single-quoted values, and escaped '\$', will expand to a literal '$'.
+//
+// gotenv always expands references, so each '$' is replaced with a reference to a sentinel variable.
+// gotenv expands the reference to the sentinel value, unless it is single-quoted or escaped,
+// leaving all quoting, escaping and multi-line handling to gotenv.
+//
+func ParseDotEnvRefs(content []byte) (map[string]string, error) {
+	content = bytes.TrimPrefix(content, []byte("\xEF\xBB\xBF")) // UTF-8 BOM
+	if bytes.HasPrefix(content, []byte("\xFF\xFE")) || bytes.HasPrefix(content, []byte("\xFE\xFF")) {
+		return nil, errors.New("UTF-16 files are not supported when expanding references")
+	}
+	// Find a sentinel name that does not appear in the content
+	//
+	var sentinel string
+	for i := 0; len(sentinel) == 0 || bytes.Contains(content, []byte(sentinel)); i++ {
+		sentinel = fmt.Sprintf("RUN_DOLLAR_SENTINEL_%d", i)
+	}
+	sentinelRef := "${" + sentinel + "}"
+	sentinelValue := sentinel + "_VALUE"
+	b := &bytes.Buffer{}
+	b.WriteString(sentinel + "=" + sentinelValue + "\n")
+	b.Write(bytes.ReplaceAll(content, []byte("$"), []byte(sentinelRef)))
+	env, err := gotenv.StrictParse(b)
+	if err != nil {
+		return nil, err
+	}
+	delete(env, sentinel)
+	values := make(map[string]string, len(env))
+	for k, v := range env {
+		v = strings.ReplaceAll(v, sentinelRef, "$$") // Single-quoted or escaped => literal '$'
+		values[k] = strings.ReplaceAll(v, sentinelValue, "$")
+	}
+	return values, nil
+}
+
+// EditDistance returns the Levenshtein distance between two strings.
+//
+func EditDistance(a string, b string) int {
+	ar, br := []rune(a), []rune(b)
+	prev := make([]int, len(br)+1)
+	curr := make([]int, len(br)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		curr[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			curr[j] = prev[j-1] + cost // Substitution
+			if prev[j]+1 < curr[j] {
+				curr[j] = prev[j] + 1 // Deletion
+			}
+			if curr[j-1]+1 < curr[j] {
+				curr[j] = curr[j-1] + 1 // Insertion
+			}
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(br)]
+}
+
 // StatIfExists lets you provide specific NotExist error handling
 // Returns stat, true, nil if file exists
 // Returns nil, false, nil if err == fs.ErrNotExist
@@ -90,3 +171,62 @@ func TryMakeRelative(basePath string, targetPath string) string {
 	}
 	return targetPath
 }
+
+// CompileGlob compiles a shell pattern into a regexp that matches the entire input.
+// Supports '*', '?' and '[...]' (including '[!...]'), with '\' escaping the next character.
+//
+func CompileGlob(pattern string) (*regexp.Regexp, error) {
+	b := &strings.Builder{}
+	b.WriteString("(?s)^")
+	runes := []rune(pattern)
+	for i := 0; i < len(runes); i++ {
+		switch r := runes[i]; r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		case '\\':
+			if i+1 < len(runes) {
+				i++
+				b.WriteString(regexp.QuoteMeta(string(runes[i])))
+			} else {
+				b.WriteString(regexp.QuoteMeta(string(r)))
+			}
+		case '[':
+			// Find the closing bracket, otherwise treat '[' as a literal
+			//
+			j := i + 1
+			if j < len(runes) && (runes[j] == '!' || runes[j] == '^') {
+				j++
+			}
+			if j < len(runes) && runes[j] == ']' {
+				j++
+			}
+			for j < len(runes) && runes[j] != ']' {
+				// Skip character classes, i.e. '[:alpha:]'
+				//
+				if runes[j] == '[' && j+1 < len(runes) && runes[j+1] == ':' {
+					if end := strings.Index(string(runes[j+2:]), ":]"); end >= 0 {
+						j += 2 + len([]rune(string(runes[j+2:])[:end])) + 2
+						continue
+					}
+				}
+				j++
+			}
+			if j >= len(runes) {
+				b.WriteString(regexp.QuoteMeta(string(r)))
+				continue
+			}
+			class := string(runes[i+1 : j])
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			b.WriteString("[" + strings.Replace(class, `\`, `\\`, -1) + "]")
+			i = j
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
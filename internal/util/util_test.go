@@ -0,0 +1,71 @@
+package util
+
+import (
+	"os"
+	"testing"
+)
+
+func TestParseDotEnvRefs(t *testing.T) {
+	content := "" +
+		"# comment with $HOME\n" +
+		"PLAIN=value\n" +
+		"UNQUOTED=$HOME/bin\n" +
+		"BRACED=${HOME}/bin\n" +
+		"DQUOTED=\"${NAME} says \\\"hi\\\"\"\n" +
+		"SQUOTED='$HOME stays'\n" +
+		"ESCAPED=\"\\$HOME\"\n" +
+		"DOUBLE=\"$$\"\n" +
+		"COLON: $A:$B\n" +
+		"URL=http://host:80/$PATH_PART\n" +
+		"MULTI=\"line one $A\n" +
+		"line \\\"two\\\" = $B\"\n" +
+		"SMULTI='line one $A\n" +
+		"line two'\n" +
+		"export EXPORTED=$A\n"
+	want := map[string]string{
+		"PLAIN":    "value",
+		"UNQUOTED": "$HOME/bin",
+		"BRACED":   "${HOME}/bin",
+		"DQUOTED":  "${NAME} says \"hi\"",
+		"SQUOTED":  "$$HOME stays",
+		"ESCAPED":  "$$HOME",
+		"DOUBLE":   "$$",
+		"COLON":    "$A:$B",
+		"URL":      "http://host:80/$PATH_PART",
+		"MULTI":    "line one $A\nline \"two\" = $B",
+		"SMULTI":   "line one $$A\nline two",
+		"EXPORTED": "$A",
+	}
+	got, err := ParseDotEnvRefs([]byte(content))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s = %q, want %q", k, got[k], v)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("got %d values, want %d: %q", len(got), len(want), got)
+	}
+	// Expanded values
+	//
+	vars := map[string]string{"HOME": "/home/me", "A": "a"}
+	if v := os.Expand(got["SQUOTED"], func(name string) string {
+		if name == "$" {
+			return "$"
+		}
+		return vars[name]
+	}); v != "$HOME stays" {
+		t.Errorf("expanded SQUOTED = %q, want %q", v, "$HOME stays")
+	}
+}
+
+func TestParseDotEnvRefsErrors(t *testing.T) {
+	if _, err := ParseDotEnvRefs([]byte("A=\"unterminated\n")); err == nil {
+		t.Errorf("expected error for unterminated quote")
+	}
+	if _, err := ParseDotEnvRefs([]byte("\xFF\xFEA=1")); err == nil {
+		t.Errorf("expected error for UTF-16 content")
+	}
+}
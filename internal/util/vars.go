@@ -0,0 +1,142 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// ParseVarsFile parses a structured (json, yaml or toml) file, based on its extension.
+//
+func ParseVarsFile(filename string, content []byte) (map[string]interface{}, error) {
+	var (
+		data = make(map[string]interface{})
+		err  error
+	)
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json":
+		err = json.Unmarshal(content, &data)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &data)
+	case ".toml":
+		err = toml.Unmarshal(content, &data)
+	default:
+		return nil, fmt.Errorf("unsupported file type (expecting .json, .yaml, .yml or .toml)")
+	}
+	return data, err
+}
+
+// SelectVarsTree selects the sub-tree of data at the '.'-separated key path.
+//
+func SelectVarsTree(data map[string]interface{}, keyPath string) (map[string]interface{}, error) {
+	for _, key := range strings.Split(keyPath, ".") {
+		value, ok := data[key]
+		if !ok {
+			return nil, fmt.Errorf("key not found: %s", keyPath)
+		}
+		if data, ok = value.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("key does not reference a table/object: %s", keyPath)
+		}
+	}
+	return data, nil
+}
+
+// FlattenVars flattens nested keys into variable names, i.e. 'db.host' -> 'DB_HOST'.
+// Names are upper-cased, with any non-alphanumeric characters replaced by '_'.
+// List entries are named by their index, i.e. 'hosts[0]' -> 'HOSTS_0'.
+// Returns an error if a key results in an invalid variable name, i.e. '1st' -> '1ST',
+// or if two keys result in the same variable name, i.e. 'db.host' and 'db_host'.
+//
+func FlattenVars(data map[string]interface{}) (map[string]string, error) {
+	vars := make(map[string]string)
+	keyPaths := make(map[string]string) // Variable name -> key path, for reporting
+	if err := flattenVars(vars, keyPaths, "", "", data); err != nil {
+		return nil, err
+	}
+	return vars, nil
+}
+
+// varNameRegex matches valid variable names
+//
+var varNameRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// flattenVars is the recursive implementation of FlattenVars.
+// Keys are processed in sorted order, so results (and errors) are consistent.
+//
+func flattenVars(vars map[string]string, keyPaths map[string]string, name string, keyPath string, value interface{}) error {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			childPath := key
+			if len(keyPath) > 0 {
+				childPath = keyPath + "." + key
+			}
+			if err := flattenVars(vars, keyPaths, joinVarName(name, key), childPath, v[key]); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []interface{}:
+		for i, item := range v {
+			if err := flattenVars(vars, keyPaths, joinVarName(name, strconv.Itoa(i)), fmt.Sprintf("%s[%d]", keyPath, i), item); err != nil {
+				return err
+			}
+		}
+		return nil
+	case []map[string]interface{}: // toml array of tables
+		for i, item := range v {
+			if err := flattenVars(vars, keyPaths, joinVarName(name, strconv.Itoa(i)), fmt.Sprintf("%s[%d]", keyPath, i), item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if !varNameRegex.MatchString(name) {
+		return fmt.Errorf("key '%s' results in invalid variable name '%s'", keyPath, name)
+	}
+	if existing, ok := keyPaths[name]; ok {
+		return fmt.Errorf("keys '%s' and '%s' both result in variable name '%s'", existing, keyPath, name)
+	}
+	keyPaths[name] = keyPath
+	switch v := value.(type) {
+	case nil:
+		vars[name] = ""
+	case string:
+		vars[name] = v
+	case float64:
+		vars[name] = strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		vars[name] = v.Format(time.RFC3339)
+	default:
+		vars[name] = fmt.Sprint(v)
+	}
+	return nil
+}
+
+// joinVarName appends the key to the variable name, normalizing it in the process.
+//
+func joinVarName(name string, key string) string {
+	key = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '_' {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(key))
+	if len(name) == 0 {
+		return key
+	}
+	return name + "_" + key
+}
@@ -0,0 +1,48 @@
+package util
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFlattenVars(t *testing.T) {
+	tests := []struct {
+		filename string
+		content  string
+		want     map[string]string
+		wantErr  string
+	}{
+		{
+			"config.yaml", "db:\n  host: localhost\n  port: 5432\nhosts: [a, b]\nname-x: y\n",
+			map[string]string{"DB_HOST": "localhost", "DB_PORT": "5432", "HOSTS_0": "a", "HOSTS_1": "b", "NAME_X": "y"}, "",
+		},
+		{
+			"config.json", `{"db": {"timeout": 1.5, "enabled": true, "extra": null}}`,
+			map[string]string{"DB_TIMEOUT": "1.5", "DB_ENABLED": "true", "DB_EXTRA": ""}, "",
+		},
+		{
+			"config.toml", "[[servers]]\nname = \"a\"\n[[servers]]\nname = \"b\"\n",
+			map[string]string{"SERVERS_0_NAME": "a", "SERVERS_1_NAME": "b"}, "",
+		},
+		{"config.yaml", "1st: x\n", nil, "key '1st' results in invalid variable name '1ST'"},
+		{"config.json", `{"db": {"host": "a"}, "db_host": "b"}`, nil, "keys 'db.host' and 'db_host' both result in variable name 'DB_HOST'"},
+		{"config.json", `{"a-b": "1", "a_b": "2"}`, nil, "keys 'a-b' and 'a_b' both result in variable name 'A_B'"},
+		{"config.json", `{"list": [["x"]]}`, map[string]string{"LIST_0_0": "x"}, ""},
+	}
+	for _, test := range tests {
+		data, err := ParseVarsFile(test.filename, []byte(test.content))
+		if err != nil {
+			t.Fatalf("%s: unexpected parse error: %v", test.content, err)
+		}
+		got, err := FlattenVars(data)
+		switch {
+		case len(test.wantErr) > 0 && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+			t.Errorf("%s: expected error containing %q, got: %v", test.content, test.wantErr, err)
+		case len(test.wantErr) == 0 && err != nil:
+			t.Errorf("%s: unexpected error: %v", test.content, err)
+		case len(test.wantErr) == 0 && !reflect.DeepEqual(got, test.want):
+			t.Errorf("%s: got %q, want %q", test.content, got, test.want)
+		}
+	}
+}
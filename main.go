@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"flag"
 	"fmt"
 	"io"
@@ -8,8 +9,11 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
+	"github.com/subosito/gotenv"
 	"github.com/tekwizely/run/internal/ast"
 	"github.com/tekwizely/run/internal/config"
 	"github.com/tekwizely/run/internal/exec"
@@ -22,12 +26,63 @@ const (
 	runfileDefault = "Runfile"
 	runfileEnv     = "RUNFILE"
 	runfileRoots   = "RUNFILE_ROOTS"
+	prefixMatchEnv = "RUN_PREFIX_MATCH"
+	profileEnv     = "RUN_PROFILE"
+	runExitCodeEnv = "RUN_EXIT_CODE"
+	// workspaceStatusEnv names the file a workspace project invocation writes its status to
+	//
+	workspaceStatusEnv = "RUN_WORKSPACE_STATUS"
 )
 
 var (
 	hidePanic = true // Hide full trace on panics
 )
 
+// varAssignmentRegex matches NAME=value, capturing NAME and value
+//
+var varAssignmentRegex = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_]*)=(.*)$`)
+
+// setVarFlag implements flag.Value for --set NAME=value
+//
+type setVarFlag struct{}
+
+func (f *setVarFlag) String() string {
+	return ""
+}
+func (f *setVarFlag) Set(s string) error {
+	match := varAssignmentRegex.FindStringSubmatch(s)
+	if match == nil {
+		return fmt.Errorf("expecting NAME=value")
+	}
+	config.VarOverrides[match[1]] = match[2]
+	return nil
+}
+
+// setFileFlag implements flag.Value for --set-file <file>
+//
+type setFileFlag struct{}
+
+func (f *setFileFlag) String() string {
+	return ""
+}
+func (f *setFileFlag) Set(filename string) error {
+	fileBytes, exists, err := util.ReadFileIfExists(filename)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("file not found: '%s'", filename)
+	}
+	vars, err := gotenv.StrictParse(bytes.NewReader(fileBytes))
+	if err != nil {
+		return fmt.Errorf("file '%s': %s", filename, err)
+	}
+	for k, v := range vars {
+		config.VarOverrides[k] = v
+	}
+	return nil
+}
+
 // showUsageHint prints a terse usage string.
 //
 func showUsageHint() {
@@ -49,12 +104,47 @@ func showRunHelp() {
 	fmt.Fprintf(config.ErrOut, "  or   %s help <command>\n", config.Me)
 	fmt.Fprintf(config.ErrOut, "       %s (show help for <command>)\n", pad)
 
+	whichName := "which"
+	if config.ShebangMode {
+		whichName = "run-which"
+	}
+	fmt.Fprintf(config.ErrOut, "  or   %s %s <command>\n", config.Me, whichName)
+	fmt.Fprintf(config.ErrOut, "       %s (show where <command> is defined)\n", pad)
+
+	checkName := "check"
+	if config.ShebangMode {
+		checkName = "run-check"
+	}
+	fmt.Fprintf(config.ErrOut, "  or   %s %s\n", config.Me, checkName)
+	fmt.Fprintf(config.ErrOut, "       %s (report references to undefined variables)\n", pad)
+
 	fmt.Fprintln(config.ErrOut, "Options:")
 	if config.EnableRunfileOverride {
 		fmt.Fprintln(config.ErrOut, "  -r, --runfile <file>")
 		fmt.Fprintf(config.ErrOut, "        Specify runfile (default='${%s:-%s}')\n", runfileEnv, runfileDefault)
 		fmt.Fprint(config.ErrOut, "        ex: run -r /my/runfile list\n")
 	}
+	if !config.ShebangMode {
+		fmt.Fprintln(config.ErrOut, "  --profile <name>")
+		fmt.Fprintf(config.ErrOut, "        Specify active profile (default='${%s}')\n", profileEnv)
+		fmt.Fprint(config.ErrOut, "        ex: run --profile staging deploy\n")
+		fmt.Fprintln(config.ErrOut, "  --set <name=value>")
+		fmt.Fprintln(config.ErrOut, "        Override runfile variable (repeatable)")
+		fmt.Fprint(config.ErrOut, "        ex: run --set VERSION=1.2 build\n")
+		fmt.Fprintln(config.ErrOut, "  --set-file <file>")
+		fmt.Fprintln(config.ErrOut, "        Override runfile variables from .env file (repeatable)")
+		fmt.Fprintln(config.ErrOut, "  Leading <name=value> args also override runfile variables")
+		fmt.Fprint(config.ErrOut, "        ex: run VERSION=1.2 build\n")
+		fmt.Fprintln(config.ErrOut, "  --strict")
+		fmt.Fprintln(config.ErrOut, "        Abort if a command substitution $(...) fails")
+		fmt.Fprintln(config.ErrOut, "  --strict-vars")
+		fmt.Fprintln(config.ErrOut, "        Abort if an undefined variable ${...} is referenced")
+		fmt.Fprintln(config.ErrOut, "  -w, --workspace")
+		fmt.Fprintln(config.ErrOut, "        Run command in each workspace runfile that defines it")
+		fmt.Fprint(config.ErrOut, "        ex: run -w test\n")
+		fmt.Fprintln(config.ErrOut, "  --workspace-parallel")
+		fmt.Fprintln(config.ErrOut, "        Run workspace runfiles in parallel (requires --workspace)")
+	}
 	fmt.Fprintln(config.ErrOut, "Note:")
 	fmt.Fprintln(config.ErrOut, "  Options accept '-' | '--'")
 	fmt.Fprintln(config.ErrOut, "  Values can be given as:")
@@ -143,6 +233,9 @@ func main() {
 		}
 	}
 	config.RunfileIsDefault = !config.ShebangMode // May change once we know more about runfile
+	// Active profile - May be overridden via --profile
+	//
+	config.Profile = util.GetEnvOrDefault(profileEnv, "")
 	var exists bool
 	var err error
 	// In shebang mode, we defer parsing args until we know if we are in "main" mode
@@ -196,8 +289,13 @@ func main() {
 		//
 		rf = ast.ProcessAST(rfAst)
 		config.RunfileIsLoaded = true
-	} else {
+		// Prefix matching enabled via attribute or env
+		//
+		prefixMatch, _ := rf.Scope.GetAttr(".RUN.PREFIX_MATCH")
+		config.PrefixMatch = util.IsTrue(prefixMatch) || util.IsTrue(os.Getenv(prefixMatchEnv))
+	} else if !config.WorkspaceMode || err != nil {
 		// NOTE: No error codes here - Allow built-in commands to be run even if runfile not found
+		// NOTE: Primary runfile is optional in workspace mode
 		//
 		if err == nil {
 			log.Printf("ERROR: runfile '%s' not found: please create the file or specify an alternative\n\n", util.DefaultIfEmpty(config.Runfile, runfileDefault)) // 2 x \n
@@ -211,6 +309,12 @@ func main() {
 			}
 		}
 	}
+	// Workspace mode runs the command within each workspace runfile, instead of the primary runfile
+	//
+	if config.WorkspaceMode {
+		exitCode = runWorkspace(rf)
+		return
+	}
 	// Setup Commands
 	//
 	listCmd := &config.Command{
@@ -249,16 +353,56 @@ func main() {
 	}
 	config.CommandMap[versionName] = versionCmd
 	config.CommandList = append(config.CommandList, versionCmd)
+	// In shebang mode, Which registered as 'run-which'
+	//
+	whichName := "which"
+	if config.ShebangMode {
+		whichName = "run-which"
+	}
+	whichCmd := &config.Command{
+		Name:    whichName,
+		Title:   "(builtin) Show where a command is defined",
+		Help:    showRunHelp,
+		Run:     func(_ []string, _ map[string]string, _ io.Writer) int { return runfile.RunWhich(whichName) },
+		Rename:  func(_ string) {},
+		Builtin: true,
+		Yields:  true,
+	}
+	config.CommandMap[whichName] = whichCmd
+	config.CommandList = append(config.CommandList, whichCmd)
+	// In shebang mode, Check registered as 'run-check'
+	//
+	checkName := "check"
+	if config.ShebangMode {
+		checkName = "run-check"
+	}
+	checkCmd := &config.Command{
+		Name:    checkName,
+		Title:   "(builtin) Report references to undefined variables",
+		Help:    showRunHelp,
+		Run:     func(_ []string, _ map[string]string, _ io.Writer) int { return runfile.RunCheck(rf) },
+		Rename:  func(_ string) {},
+		Builtin: true,
+		Yields:  true,
+	}
+	config.CommandMap[checkName] = checkCmd
+	config.CommandList = append(config.CommandList, checkCmd)
 	builtinCnt := len(config.CommandList)
 
 	// Register runfile commands, if loaded
 	//
+	var defaultCmdName string
 	if rf != nil {
-		firstRunCommandsByName := make(map[string]*runfile.RunCmd) // Keep track of first occurrences for name and default title/description
-		runCommandsByFileAndName := make(map[string]map[string]*runfile.RunCmd)
-		runCommandsByName := make(map[string]*runfile.RunCmd)
-		for _, cmdProvider := range rf.Cmds {
-			newRunCommand := cmdProvider.GetCmd(rf)
+		firstRunCommandsByName := make(map[string]*runfile.CmdInfo) // Keep track of first occurrences for name and default title/description
+		firstProvidersByName := make(map[string]runfile.CmdProvider)
+		runCommandsByFileAndName := make(map[string]map[string]*runfile.CmdInfo)
+		runCommandsByName := make(map[string]*runfile.CmdInfo)
+		runCommandIndexByName := make(map[string]int) // Definition order of the active command, used to resolve alias overrides
+		// Commands are registered from their metadata,
+		// their values are only evaluated when listed, or when showing help
+		//
+		for cmdIndex, cmdProvider := range rf.Cmds {
+			newRunCommand := cmdProvider.GetCmdInfo(rf)
 			newRunCommandFlags := newRunCommand.Flags   // May override later
 			newRunCommandName := newRunCommand.Name     // Un-normalized name used for help
 			name := strings.ToLower(newRunCommand.Name) // normalize
@@ -266,12 +410,36 @@ func main() {
 			//
 			runCommandsByNameForFile, ok := runCommandsByFileAndName[newRunCommand.Runfile]
 			if !ok {
-				runCommandsByNameForFile = make(map[string]*runfile.RunCmd)
+				runCommandsByNameForFile = make(map[string]*runfile.CmdInfo)
 				runCommandsByFileAndName[newRunCommand.Runfile] = runCommandsByNameForFile
 			}
+			// Track definitions for 'which'
+			//
+			definition := &config.CmdDefinition{Runfile: newRunCommand.Runfile, Line: newRunCommand.Line, Shell: newRunCommand.Shell}
+			definitions := []*config.CmdDefinition{definition}
+			var descFrom *config.CmdDefinition
+			if newRunCommand.HasDesc {
+				descFrom = definition
+			}
+			// Builtins added after runfiles could already define the name give way to the runfile command
+			//
+			if existingConfigCommand, ok := config.CommandMap[name]; ok && existingConfigCommand.Yields {
+				unregisterCommand(existingConfigCommand)
+				builtinCnt--
+				if config.ShowNotices {
+					log.Printf("NOTICE: %s:%d command %s replaces built-in command %s", newRunCommand.Runfile, newRunCommand.Line, newRunCommand.Name, existingConfigCommand.Name)
+				}
+			}
+			// Title/Description and Group, evaluated on demand
+			//
+			descProvider := cmdProvider
+			var groupProvider runfile.CmdProvider
 			// Look for dupes
 			//
 			configIndex := -1 // Keep original CommandList index when overriding commands; Makes help lists consistent
+			// Overridden command, invoked via 'RUN ^'
+			//
+			var superCommand *config.Command
 			if firstRunCommand, ok := firstRunCommandsByName[name]; ok {
 				existingConfigCommand := config.CommandMap[name] // Should ALWAYS succeed
 				// Can't override builtin commands
@@ -284,13 +452,16 @@ func main() {
 				if oldRunCommandForFile, ok := runCommandsByNameForFile[name]; ok {
 					panic(fmt.Sprintf("%s: command %s defined multiple times in the same file: lines %d and %d", newRunCommand.Runfile, name, oldRunCommandForFile.Line, newRunCommand.Line))
 				}
-				// OK to override command, but notify user
+				// OK to override command, but warn user if override not explicit
 				//
-				if config.ShowNotices {
-					if oldRunCommand, ok := runCommandsByName[name]; ok { // Should ALWAYS succeed
+				if oldRunCommand, ok := runCommandsByName[name]; ok { // Should ALWAYS succeed
+					if !newRunCommand.Flags.Override() {
+						log.Printf("WARNING: %s:%d command %s overrides command %s defined in %s:%d without OVERRIDE", newRunCommand.Runfile, newRunCommand.Line, newRunCommand.Name, oldRunCommand.Name, oldRunCommand.Runfile, oldRunCommand.Line)
+					} else if config.ShowNotices {
 						log.Printf("NOTICE: %s:%d command %s overrides command %s defined in %s:%d", newRunCommand.Runfile, newRunCommand.Line, newRunCommand.Name, oldRunCommand.Name, oldRunCommand.Runfile, oldRunCommand.Line)
 					}
 				}
+				superCommand = existingConfigCommand
 				// Remove old command, taking note of command flags, name and CommandList index, which will be re-used
 				//
 				delete(config.CommandMap, name) // Technically not needed but feels cleaner
@@ -308,26 +479,59 @@ func main() {
 				newRunCommandName = firstRunCommand.Name
 				// If no Title/Description defined, use first command's as its considered canonical
 				//
-				if len(newRunCommand.Config.Desc) == 0 {
-					newRunCommand.Config.Desc = firstRunCommand.Config.Desc // TODO Make defensive copy?
+				if !newRunCommand.HasDesc {
+					descProvider = firstProvidersByName[name]
+					descFrom = nil
+					if firstRunCommand.HasDesc {
+						descFrom = existingConfigCommand.Definitions[0]
+					}
 				}
+				// If no Aliases defined, use first command's
+				//
+				if len(newRunCommand.Aliases) == 0 {
+					newRunCommand.Aliases = firstRunCommand.Aliases
+				}
+				// If no Group defined, use first command's
+				//
+				groupProvider = firstProvidersByName[name]
+				definitions = append(append([]*config.CmdDefinition{}, existingConfigCommand.Definitions...), definition)
+			} else if newRunCommand.Flags.Override() && config.ShowNotices {
+				// OVERRIDE, but nothing to override - OK, as the overridden command may be optional, but notify user
+				//
+				log.Printf("NOTICE: %s:%d command %s marked OVERRIDE but does not override another command", newRunCommand.Runfile, newRunCommand.Line, newRunCommand.Name)
 			}
 			// Register cmd
 			//
 			runCommandsByNameForFile[name] = newRunCommand
 			runCommandsByName[name] = newRunCommand
+			runCommandIndexByName[name] = cmdIndex
+			helpName := newRunCommand.Name
 			cmd := &config.Command{
 				Flags: newRunCommandFlags,
 				Name:  newRunCommandName,
-				Title: newRunCommand.Title(),
-				Help:  func(c *runfile.RunCmd) func() { return func() { runfile.ShowCmdHelp(c) } }(newRunCommand),
-				Run: func(a runfile.CmdProvider) func([]string, map[string]string, io.Writer) int {
+				Describe: func(a, desc, group runfile.CmdProvider) func() (string, string) {
+					return func() (string, string) {
+						return describeRunCommand(rf, a, desc, group)
+					}
+				}(cmdProvider, descProvider, groupProvider),
+				Help: func(a, desc runfile.CmdProvider, info *runfile.CmdInfo, name *string) func() {
+					return func() {
+						c := a.GetCmd(rf)
+						c.Name = *name
+						c.Config.Desc = desc.DescribeCmd(rf).Config.Desc
+						c.Config.Aliases = info.Aliases
+						runfile.ShowCmdHelp(c)
+					}
+				}(cmdProvider, descProvider, newRunCommand, &helpName),
+				Run: func(a runfile.CmdProvider, super *config.Command) func([]string, map[string]string, io.Writer) int {
 					return func(args []string, env map[string]string, out io.Writer) int {
-						return runfile.RunCommand(a, rf, args, env, out)
+						return runfile.RunCommand(a, rf, super, args, env, out)
 					}
-				}(cmdProvider),
-				Rename:  func(c *runfile.RunCmd) func(string) { return func(s string) { c.Name = s } }(newRunCommand),
-				Builtin: false,
+				}(cmdProvider, superCommand),
+				Rename:      func(name *string) func(string) { return func(s string) { *name = s } }(&helpName),
+				Builtin:     false,
+				Definitions: definitions,
+				DescFrom:    descFrom,
 			}
 			config.CommandMap[name] = cmd
 			// Append or replace?
@@ -337,6 +541,122 @@ func main() {
 			} else {
 				config.CommandList = append(config.CommandList, cmd)
 				firstRunCommandsByName[name] = newRunCommand
+				firstProvidersByName[name] = cmdProvider
+			}
+		}
+		// Register aliases, now that all commands (and overrides) are known
+		// Aliases follow the same rules as commands: They cannot override builtin commands,
+		// nor names defined in the same runfile, otherwise the later definition wins
+		//
+		aliasRunCommandsByName := make(map[string]*runfile.CmdInfo)
+		aliasIndexByName := make(map[string]int)
+		overriddenCommands := make(map[*config.Command]struct{})
+		for _, cmd := range config.CommandList[builtinCnt:] {
+			runCommand := runCommandsByName[strings.ToLower(cmd.Name)]
+			runCommandIndex := runCommandIndexByName[strings.ToLower(cmd.Name)]
+			for _, alias := range runCommand.Aliases {
+				aliasName := strings.ToLower(alias) // normalize
+				// Builtins added after runfiles could already define the name give way to the alias
+				//
+				if existingConfigCommand, ok := config.CommandMap[aliasName]; ok && existingConfigCommand.Yields {
+					unregisterCommand(existingConfigCommand)
+					builtinCnt--
+					if config.ShowNotices {
+						log.Printf("NOTICE: %s:%d alias %s of command %s replaces built-in command %s", runCommand.Runfile, runCommand.Line, alias, runCommand.Name, existingConfigCommand.Name)
+					}
+				}
+				if existingConfigCommand, ok := config.CommandMap[aliasName]; ok {
+					// Can't override builtin commands
+					//
+					if existingConfigCommand.Builtin {
+						panic(fmt.Sprintf("%s:%d cannot override built-in command %s", runCommand.Runfile, runCommand.Line, aliasName))
+					}
+					// Alias of itself, or repeated alias - Nothing to do
+					//
+					if existingConfigCommand == cmd {
+						continue
+					}
+					// Can't override commands defined in same runfile
+					//
+					var existingDesc string
+					existingRunCommand, isAlias := aliasRunCommandsByName[aliasName]
+					existingIndex := aliasIndexByName[aliasName]
+					if isAlias {
+						existingDesc = fmt.Sprintf("alias %s of command %s", aliasName, existingRunCommand.Name)
+					} else {
+						existingRunCommand = runCommandsByName[aliasName]
+						existingIndex = runCommandIndexByName[aliasName]
+						existingDesc = fmt.Sprintf("command %s", existingRunCommand.Name)
+					}
+					if existingRunCommand.Runfile == runCommand.Runfile {
+						panic(fmt.Sprintf("%s: command %s defined multiple times in the same file: lines %d and %d", runCommand.Runfile, aliasName, existingRunCommand.Line, runCommand.Line))
+					}
+					// OK to override, but warn user if override not explicit
+					//
+					newRunCommand, newDesc := runCommand, fmt.Sprintf("alias %s of command %s", alias, runCommand.Name)
+					oldRunCommand, oldDesc := existingRunCommand, existingDesc
+					if existingIndex > runCommandIndex {
+						newRunCommand, newDesc, oldRunCommand, oldDesc = oldRunCommand, oldDesc, newRunCommand, newDesc
+					}
+					if !newRunCommand.Flags.Override() {
+						log.Printf("WARNING: %s:%d %s overrides %s defined in %s:%d without OVERRIDE", newRunCommand.Runfile, newRunCommand.Line, newDesc, oldDesc, oldRunCommand.Runfile, oldRunCommand.Line)
+					} else if config.ShowNotices {
+						log.Printf("NOTICE: %s:%d %s overrides %s defined in %s:%d", newRunCommand.Runfile, newRunCommand.Line, newDesc, oldDesc, oldRunCommand.Runfile, oldRunCommand.Line)
+					}
+					// Existing definition is later, so it wins
+					//
+					if existingIndex > runCommandIndex {
+						continue
+					}
+					// Remove the overridden alias or command
+					//
+					if isAlias {
+						for i, existingAlias := range existingConfigCommand.Aliases {
+							if strings.ToLower(existingAlias) == aliasName {
+								existingConfigCommand.Aliases = append(existingConfigCommand.Aliases[:i:i], existingConfigCommand.Aliases[i+1:]...)
+								break
+							}
+						}
+					} else {
+						overriddenCommands[existingConfigCommand] = struct{}{}
+					}
+				}
+				aliasRunCommandsByName[aliasName] = runCommand
+				aliasIndexByName[aliasName] = runCommandIndex
+				config.CommandMap[aliasName] = cmd
+				cmd.Aliases = append(cmd.Aliases, alias)
+			}
+		}
+		// Commands overridden by aliases are no longer available, including via their own aliases
+		//
+		if len(overriddenCommands) > 0 {
+			commandList := config.CommandList[:0:0]
+			for _, cmd := range config.CommandList {
+				if _, overridden := overriddenCommands[cmd]; !overridden {
+					commandList = append(commandList, cmd)
+				}
+			}
+			config.CommandList = commandList
+			for name, cmd := range config.CommandMap {
+				if _, overridden := overriddenCommands[cmd]; overridden {
+					delete(config.CommandMap, name)
+				}
+			}
+		}
+		// Default command - .DEFAULT attribute takes precedence over DEFAULT doc block marker
+		//
+		if defaultCmdName, _ = rf.Scope.GetAttr(".DEFAULT"); len(defaultCmdName) == 0 {
+			var defaultRunCommand *runfile.CmdInfo
+			for _, cmd := range config.CommandList[builtinCnt:] {
+				runCommand := runCommandsByName[strings.ToLower(cmd.Name)]
+				if !runCommand.Default {
+					continue
+				}
+				if defaultRunCommand != nil {
+					panic(fmt.Sprintf("%s:%d DEFAULT command %s conflicts with DEFAULT command %s defined in %s:%d", runCommand.Runfile, runCommand.Line, runCommand.Name, defaultRunCommand.Name, defaultRunCommand.Runfile, defaultRunCommand.Line))
+				}
+				defaultRunCommand = runCommand
+				defaultCmdName = runCommand.Name
 			}
 		}
 	}
@@ -372,6 +692,11 @@ func main() {
 				cmdName = strings.TrimPrefix(cmdName, ".")
 				cmdShowHidden = true
 			}
+		} else if len(defaultCmdName) > 0 {
+			// Default command - May be hidden
+			//
+			cmdName = defaultCmdName
+			cmdShowHidden = true
 		} else {
 			//
 			// Default (no command) action
@@ -397,23 +722,105 @@ func main() {
 	// Hidden == not present unless command invoked with `@NAME`
 	//
 	cmdName = strings.ToLower(cmdName) // normalize
+	// Sub-commands - Resolve longest matching path, i.e. 'db migrate up' => 'db/migrate/up'
+	//
+	if !config.MainMode {
+		cmdName, os.Args = runfile.ResolveCommandPath(cmdName, os.Args, cmdShowHidden)
+	}
+	// Unique prefix, i.e. 'dep' => 'deploy' - If enabled
+	//
+	prefixName, prefixMatches := runfile.ResolveCommandPrefix(cmdName, cmdShowHidden)
+	if len(prefixMatches) > 1 {
+		log.Printf("ambiguous command: %s", cmdName)
+		runfile.ListCommandSuggestions(prefixMatches)
+		showUsageHint()
+		exitCode = 2
+		return
+	}
+	cmdName = prefixName
 	var cmd *config.Command
 	var ok bool
 	if cmd, ok = config.CommandMap[cmdName]; !ok || cmd.Flags.Private() || (cmd.Flags.Hidden() && !cmdShowHidden) {
+		// Workspace project? Let workspace mode report the command as not defined
+		//
+		if config.WorkspaceProject {
+			writeWorkspaceStatus(workspaceNotDefined)
+			exitCode = 2
+			return
+		}
+		// Parent of sub-commands? List them
+		//
+		if !ok && runfile.HasSubCommands(cmdName, cmdShowHidden) {
+			if len(os.Args) > 0 {
+				log.Printf("command not found: %s %s\n\n", strings.ReplaceAll(cmdName, "/", " "), os.Args[0]) // 2 x \n
+			}
+			runfile.ListSubCommands(cmdName, cmdShowHidden)
+			exitCode = 2
+			return
+		}
 		// TODO HACK : If we get here via Runfile not found, don't display cmd error
 		//
 		if rf != nil {
 			log.Printf("command not found: %s", cmdName)
-			runfile.ListCommands()
+			runfile.ListCommandsOrSuggestions(cmdName, cmdShowHidden)
 			showUsageHint()
 		}
 		exitCode = 2
 		return
 	}
+	// Commands may be invoked via alias, use canonical name from here on
+	//
+	cmdName = strings.ToLower(cmd.Name)
 	// Mark primary command as being run (to void RUN loops)
 	//
 	config.RunCycleMap[cmdName] = struct{}{}
+	// BEFORE.ALL / AFTER.ALL hooks only wrap runfile commands
+	//
+	if cmd.Builtin {
+		exitCode = cmd.Run(os.Args, map[string]string{}, os.Stdout)
+		return
+	}
+	if exitCode = runfile.RunHooks(rf.BeforeAll, cmdName, map[string]string{}, os.Stdout); exitCode != 0 {
+		return
+	}
 	exitCode = cmd.Run(os.Args, map[string]string{}, os.Stdout)
+	// AFTER.ALL hooks always run, with the command's exit code exported, i.e. for reporting
+	// The command's exit code takes priority over the hooks'
+	//
+	afterEnv := map[string]string{runExitCodeEnv: strconv.Itoa(exitCode)}
+	if afterExitCode := runfile.RunHooks(rf.AfterAll, cmdName, afterEnv, os.Stdout); exitCode == 0 {
+		exitCode = afterExitCode
+	}
+}
+
+// unregisterCommand removes the command from the command list and map.
+// The command list is replaced, rather than modified, so is safe to call while iterating it.
+//
+func unregisterCommand(cmd *config.Command) {
+	commandList := config.CommandList[:0:0]
+	for _, c := range config.CommandList {
+		if c != cmd {
+			commandList = append(commandList, c)
+		}
+	}
+	config.CommandList = commandList
+	for name, c := range config.CommandMap {
+		if c == cmd {
+			delete(config.CommandMap, name)
+		}
+	}
+}
+
+// describeRunCommand evaluates the title and group of a runfile command, for listing.
+// desc provides the title, group provides the group if the command does not define one (may be nil).
+//
+func describeRunCommand(rf *runfile.Runfile, a runfile.CmdProvider, desc runfile.CmdProvider, group runfile.CmdProvider) (string, string) {
+	title := desc.DescribeCmd(rf).Title()
+	groupName := a.DescribeCmd(rf).Config.Group.Get()
+	if len(groupName) == 0 && group != nil {
+		groupName = group.DescribeCmd(rf).Config.Group.Get()
+	}
+	return title, groupName
 }
 
 func parseArgs() int {
@@ -430,6 +837,19 @@ func parseArgs() int {
 		flag.StringVar(&config.Runfile, "runfile", defaultInputFile, "")
 		flag.StringVar(&config.Runfile, "r", defaultInputFile, "")
 	}
+	// No --set/--set-file support in shebang mode, as args are parsed after the runfile is processed
+	//
+	if !config.ShebangMode {
+		flag.Var(&setVarFlag{}, "set", "")
+		flag.Var(&setFileFlag{}, "set-file", "")
+		flag.StringVar(&config.Profile, "profile", config.Profile, "")
+		flag.BoolVar(&config.StrictShell, "strict", false, "")
+		flag.BoolVar(&config.StrictVars, "strict-vars", false, "")
+		flag.BoolVar(&config.WorkspaceMode, "workspace", false, "")
+		flag.BoolVar(&config.WorkspaceMode, "w", false, "")
+		flag.BoolVar(&config.WorkspaceParallel, "workspace-parallel", false, "")
+		flag.BoolVar(&config.WorkspaceProject, "workspace-project", false, "")
+	}
 	exitCode := 0
 	// Invoked if error parsing args - sets exit code 2
 	//
@@ -447,7 +867,24 @@ func parseArgs() int {
 		showRunHelp()
 		return 2
 	}
+	if config.WorkspaceParallel && !config.WorkspaceMode {
+		log.Println("ERROR: --workspace-parallel requires --workspace")
+		showUsageHint()
+		return 2
+	}
 	os.Args = flag.Args()
+	// Leading NAME=value args are variable overrides
+	//
+	if !config.ShebangMode {
+		for len(os.Args) > 0 {
+			match := varAssignmentRegex.FindStringSubmatch(os.Args[0])
+			if match == nil {
+				break
+			}
+			config.VarOverrides[match[1]] = match[2]
+			os.Args = os.Args[1:]
+		}
+	}
 	return 0
 }
 
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"os"
+	osexec "os/exec"
+	"strings"
+	"testing"
+)
+
+// TestMain runs main instead of the tests when invoked by runMain or as a workspace project,
+// as workspace mode invokes the current executable for each project.
+//
+func TestMain(m *testing.M) {
+	if os.Getenv("RUN_TEST_MAIN") == "1" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// runMain invokes run (via the test executable) within dir, returning its combined output and exit code.
+//
+func runMain(t *testing.T, dir string, args ...string) (string, int) {
+	t.Helper()
+	cmd := osexec.Command(os.Args[0], args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "RUN_TEST_MAIN=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if exitErr, ok := err.(*osexec.ExitError); ok {
+			return string(out), exitErr.ExitCode()
+		}
+		t.Fatal(err)
+	}
+	return string(out), 0
+}
+
+// mainTest is a run invocation, along with the expected exit code and output.
+//
+type mainTest struct {
+	name     string
+	args     []string
+	exitCode int
+	want     []string // Substrings of the expected output
+}
+
+// runMainTests checks each invocation within dir exits, and outputs, as expected.
+//
+func runMainTests(t *testing.T, dir string, tests []mainTest) {
+	t.Helper()
+	for _, test := range tests {
+		out, exitCode := runMain(t, dir, test.args...)
+		if exitCode != test.exitCode {
+			t.Errorf("%s: exit code = %d, want %d\noutput:\n%s", test.name, exitCode, test.exitCode, out)
+		}
+		for _, want := range test.want {
+			if !strings.Contains(out, want) {
+				t.Errorf("%s: output missing %q\noutput:\n%s", test.name, want, out)
+			}
+		}
+	}
+}
+
+func TestWhich(t *testing.T) {
+	dir := writeWorkspace(t, map[string]string{
+		"Runfile":     "##\n# Build it\nbuild:\n  echo build\n\nINCLUDE inc/Runfile\n",
+		"inc/Runfile": "OVERRIDE build:\n  echo build2\n",
+	})
+	runMainTests(t, dir, []mainTest{
+		{"override chain", []string{"which", "build"}, 0, []string{
+			"build:\nDefinitions (in override order):\n  Runfile:3\n  inc/Runfile:1 (active)\n",
+			"Description From:\n  Runfile:3\n",
+			"Shell:\n  sh\n",
+		}},
+		{"not found", []string{"which", "nope"}, 2, []string{"command not found: nope"}},
+		{"no command", []string{"which"}, 2, []string{"which <command>'"}},
+	})
+}
+
+func TestCheck(t *testing.T) {
+	dir := writeWorkspace(t, map[string]string{
+		"Runfile":       "A := ${NOPE}\nbuild:\n  echo ${A}\n",
+		"clean.Runfile": "A := a\nbuild:\n  echo ${A}\n",
+	})
+	runMainTests(t, dir, []mainTest{
+		{"undefined", []string{"check"}, 1, []string{"Runfile:1: variable not defined: NOPE"}},
+		{"clean", []string{"--runfile", "clean.Runfile", "check"}, 0, nil},
+	})
+}
+
+func TestListGroups(t *testing.T) {
+	dir := writeWorkspace(t, map[string]string{
+		"Runfile": "##\n# Plain cmd\nplain:\n  echo\n\n" +
+			"##\n# Deploy it\n# GROUP Deploy\ndeploy:\n  echo\n\n" +
+			".GROUP := Build\n##\n# Compile it\ncompile:\n  echo\n\n##\n# Bundle it\nbundle:\n  echo\n",
+	})
+	runMainTests(t, dir, []mainTest{
+		{"groups", []string{"list"}, 0, []string{
+			"Commands:\n  plain      Plain cmd\n\n" +
+				"Deploy:\n  deploy     Deploy it\n\n" +
+				"Build:\n  compile    Compile it\n  bundle     Bundle it\n\n" +
+				"Builtins:\n  list       (builtin) List available commands\n",
+		}},
+	})
+}
+
+func TestIncludeNamespace(t *testing.T) {
+	dir := writeWorkspace(t, map[string]string{
+		"Runfile": "##\n# Top build\nbuild:\n  echo top build\n\n##\n# Top only\ntop:\n  echo top only\n\nINCLUDE ci/Runfile AS ci\n",
+		"ci/Runfile": "##\n# CI build\nbuild:\n  echo ci build\n\n" +
+			"##\n# CI test\n# RUN build\ntest:\n  echo ci test\n\n" +
+			"##\n# Uses top\n# RUN top\nuses:\n  echo uses\n",
+	})
+	runMainTests(t, dir, []mainTest{
+		{"listed as group", []string{"list"}, 0, []string{"ci:\n  ci:build    CI build\n  ci:test     CI test\n"}},
+		{"no clash", []string{"build"}, 0, []string{"top build\n"}},
+		{"namespaced", []string{"ci:build"}, 0, []string{"ci build\n"}},
+		{"own namespace first", []string{"ci:test"}, 0, []string{"ci build\nci test\n"}},
+		{"top-level fallback", []string{"ci:uses"}, 0, []string{"top only\nuses\n"}},
+	})
+}
+
+func TestDefaultCommand(t *testing.T) {
+	dir := writeWorkspace(t, map[string]string{
+		"Runfile":           "##\n# Build it\n# DEFAULT\nbuild:\n  echo build\n\nother:\n  echo other\n",
+		"attr.Runfile":      ".DEFAULT := other\n\n##\n# DEFAULT\nbuild:\n  echo build\n\nother:\n  echo other\n",
+		"duplicate.Runfile": "##\n# DEFAULT\nbuild:\n  echo build\n\n##\n# DEFAULT\nother:\n  echo other\n",
+		"none.Runfile":      "build:\n  echo build\n",
+	})
+	runMainTests(t, dir, []mainTest{
+		{"marker", nil, 0, []string{"build\n"}},
+		{"explicit command", []string{"other"}, 0, []string{"other\n"}},
+		{"attribute takes precedence", []string{"--runfile", "attr.Runfile"}, 0, []string{"other\n"}},
+		{"duplicate", []string{"--runfile", "duplicate.Runfile"}, 1, []string{"duplicate.Runfile:8 DEFAULT command other conflicts with DEFAULT command build defined in duplicate.Runfile:3"}},
+		{"no default", []string{"--runfile", "none.Runfile"}, 2, []string{"Commands:\n"}},
+	})
+}
+
+func TestCommandSuggestions(t *testing.T) {
+	dir := writeWorkspace(t, map[string]string{
+		"Runfile": "build:\n  echo build\ndeploy:\n  echo deploy\ndestroy:\n  echo destroy\n",
+	})
+	runMainTests(t, dir, []mainTest{
+		{"typo", []string{"biuld"}, 2, []string{"command not found: biuld\nDid you mean:\n  build"}},
+		{"prefix match disabled", []string{"dep"}, 2, []string{"command not found: dep\nDid you mean:\n  deploy"}},
+	})
+	t.Setenv(prefixMatchEnv, "1")
+	runMainTests(t, dir, []mainTest{
+		{"unique prefix", []string{"dep"}, 0, []string{"deploy\n"}},
+		{"ambiguous prefix", []string{"de"}, 2, []string{"ambiguous command: de\nDid you mean:\n  deploy", "  destroy"}},
+	})
+}
+
+func TestSetFile(t *testing.T) {
+	dir := writeWorkspace(t, map[string]string{
+		"Runfile":  "EXPORT A := a\nEXPORT B := b\nshow:\n  echo \"A=${A} B=${B}\"\n",
+		"vars.env": "A=file\nB=\"file b\"\n",
+		"bad.env":  "A\n",
+	})
+	runMainTests(t, dir, []mainTest{
+		{"no overrides", []string{"show"}, 0, []string{"A=a B=b\n"}},
+		{"set-file", []string{"--set-file", "vars.env", "show"}, 0, []string{"A=file B=file b\n"}},
+		{"set after set-file", []string{"--set-file", "vars.env", "--set", "A=set", "show"}, 0, []string{"A=set B=file b\n"}},
+		{"set-file after set", []string{"--set", "A=set", "--set-file", "vars.env", "show"}, 0, []string{"A=file B=file b\n"}},
+		{"not found", []string{"--set-file", "nope.env", "show"}, 2, []string{"file not found: 'nope.env'"}},
+		{"invalid", []string{"--set-file", "bad.env", "show"}, 2, []string{"file 'bad.env': "}},
+	})
+}
+
+func TestProfiles(t *testing.T) {
+	dir := writeWorkspace(t, map[string]string{
+		"Runfile": "INCLUDE.ENV .env\nINCLUDE.ENV[prod, production] prod.env\nEXPORT REGION, DB, TIER\nEXPORT .PROFILE AS PROFILE\n\n" +
+			"deploy:\n  echo \"profile=${PROFILE} region=${REGION} db=${DB} tier=${TIER}\"\n",
+		".env":         "REGION=us-east\nDB=localhost\nTIER=free\n",
+		".env.staging": "DB=staging-db\n",
+		"prod.env":     "TIER=paid\n",
+	})
+	runMainTests(t, dir, []mainTest{
+		{"no profile", []string{"deploy"}, 0, []string{"profile= region=us-east db=localhost tier=free\n"}},
+		{"profile file", []string{"--profile", "staging", "deploy"}, 0, []string{"profile=staging region=us-east db=staging-db tier=free\n"}},
+		{"profile include", []string{"--profile", "production", "deploy"}, 0, []string{"profile=production region=us-east db=localhost tier=paid\n"}},
+	})
+	t.Setenv(profileEnv, "staging")
+	runMainTests(t, dir, []mainTest{
+		{"env", []string{"deploy"}, 0, []string{"profile=staging region=us-east db=staging-db tier=free\n"}},
+		{"flag takes precedence", []string{"--profile", "prod", "deploy"}, 0, []string{"profile=prod region=us-east db=localhost tier=paid\n"}},
+	})
+}
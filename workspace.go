@@ -0,0 +1,292 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	osexec "os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/goreleaser/fileglob"
+	"github.com/tekwizely/run/internal/config"
+	"github.com/tekwizely/run/internal/runfile"
+	"github.com/tekwizely/run/internal/util"
+)
+
+// workspaceNotDefined is the status a workspace project invocation reports, when the project Runfile does not define the command.
+// The status is reported via a file, rather than the exit code, so it cannot be confused with the command's own exit code.
+//
+const workspaceNotDefined = "not defined"
+
+// workspaceProject captures a single Runfile within the workspace.
+//
+type workspaceProject struct {
+	name     string // Project dir, relative to the workspace root, for reporting
+	runfile  string // Absolute path to the project Runfile
+	status   string // Status reported by the invocation, if any
+	exitCode int
+}
+
+// defined returns true if the project Runfile defined the command.
+//
+func (p *workspaceProject) defined() bool {
+	return p.status != workspaceNotDefined
+}
+
+// writeWorkspaceStatus reports the status of a workspace project invocation,
+// via the file named by $RUN_WORKSPACE_STATUS.
+//
+func writeWorkspaceStatus(status string) {
+	if file := os.Getenv(workspaceStatusEnv); len(file) > 0 {
+		if err := ioutil.WriteFile(file, []byte(status), 0600); err != nil {
+			log.Printf("ERROR: %s", err)
+		}
+	}
+}
+
+// headerWriter writes the project header before the first write to either of its outputs,
+// so projects that do not define the command produce no output.
+//
+type headerWriter struct {
+	out     io.Writer
+	project *workspaceProject
+	once    *sync.Once
+}
+
+// Write implements io.Writer.
+//
+func (w *headerWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		_, _ = fmt.Fprintf(config.ErrOut, "==> %s\n", w.project.name)
+	})
+	return w.out.Write(p)
+}
+
+// workspaceJobs returns the maximum number of projects to execute in parallel.
+// Defaults to the number of CPUs, configurable via the .WORKSPACE.JOBS attribute of the primary Runfile.
+//
+func workspaceJobs(rf *runfile.Runfile) (int, error) {
+	var value string
+	if rf != nil {
+		value, _ = rf.Scope.GetAttr(".WORKSPACE.JOBS")
+	}
+	return util.ParseJobs(".WORKSPACE.JOBS", value)
+}
+
+// workspaceRunfiles returns the absolute paths of the Runfiles within the workspace.
+// If the primary Runfile defines the .WORKSPACE attribute, its entries are used,
+// otherwise all Runfiles found under the workspace root are used.
+// Entries are globs, relative to the workspace root, matching either project dirs or Runfiles.
+//
+func workspaceRunfiles(rf *runfile.Runfile, root string) ([]string, error) {
+	runfileName := runfileDefault
+	if len(config.Runfile) > 0 {
+		runfileName = filepath.Base(config.Runfile)
+	}
+	var patterns []string
+	if rf != nil {
+		if workspace, ok := rf.Scope.GetAttr(".WORKSPACE"); ok {
+			patterns = strings.Fields(workspace)
+			if len(patterns) == 0 {
+				return nil, fmt.Errorf(".WORKSPACE attribute defines no entries")
+			}
+		}
+	}
+	if patterns == nil {
+		patterns = []string{filepath.Join("**", runfileName)}
+	}
+	seen := make(map[string]struct{})
+	var files []string
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(root, pattern)
+		}
+		matches := []string{pattern}
+		if fileglob.ContainsMatchers(pattern) {
+			var err error
+			if matches, err = fileglob.Glob(pattern, fileglob.MaybeRootFS); err != nil {
+				return nil, fmt.Errorf("processing workspace pattern '%s': %s", pattern, err)
+			}
+		}
+		for _, match := range matches {
+			stat, exists, err := util.StatIfExists(match)
+			if err != nil {
+				return nil, err
+			}
+			if !exists {
+				log.Printf("WARNING: workspace entry not found: %s", match)
+				continue
+			}
+			// Project dirs imply the Runfile within them
+			//
+			if stat.IsDir() {
+				match = filepath.Join(match, runfileName)
+				if _, exists, err = util.StatIfExists(match); err != nil {
+					return nil, err
+				} else if !exists {
+					continue
+				}
+			}
+			match = filepath.Clean(match)
+			// Skip the primary Runfile, and duplicates
+			//
+			if _, ok := seen[match]; ok || match == config.RunfileAbs {
+				continue
+			}
+			seen[match] = struct{}{}
+			files = append(files, match)
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// workspaceArgs returns the options to pass along to each project, i.e. --profile, --set, etc.
+//
+func workspaceArgs() []string {
+	var args []string
+	if len(config.Profile) > 0 {
+		args = append(args, "--profile", config.Profile)
+	}
+	if config.StrictShell {
+		args = append(args, "--strict")
+	}
+	if config.StrictVars {
+		args = append(args, "--strict-vars")
+	}
+	names := make([]string, 0, len(config.VarOverrides))
+	for name := range config.VarOverrides {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		args = append(args, "--set", name+"="+config.VarOverrides[name])
+	}
+	return args
+}
+
+// runWorkspaceProject invokes run against the project Runfile, with the project dir as the working directory.
+// Records the exit code, and reported status, of the invocation.
+//
+func runWorkspaceProject(bin string, project *workspaceProject, args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) {
+	statusFile, err := ioutil.TempFile("", "run-workspace-status-")
+	if err != nil {
+		log.Printf("ERROR: %s: %s", project.name, err)
+		project.exitCode = 1
+		return
+	}
+	_ = statusFile.Close()
+	defer func() { _ = os.Remove(statusFile.Name()) }()
+	cmd := osexec.Command(bin, append([]string{"--runfile", project.runfile}, args...)...)
+	cmd.Dir = filepath.Dir(project.runfile)
+	cmd.Env = append(os.Environ(), workspaceStatusEnv+"="+statusFile.Name())
+	cmd.Stdin = stdin
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err = cmd.Run(); err != nil {
+		if exitErr, ok := err.(*osexec.ExitError); ok {
+			project.exitCode = exitErr.ExitCode()
+		} else {
+			log.Printf("ERROR: %s: %s", project.name, err)
+			project.exitCode = 1
+		}
+	}
+	if status, err := ioutil.ReadFile(statusFile.Name()); err == nil {
+		project.status = string(status)
+	}
+}
+
+// runWorkspace invokes the command within each workspace Runfile that defines it,
+// either sequentially or in parallel (see util.RunParallel).
+// All projects are executed, even if one fails.
+// Reports a pass/fail table, returning the exit code of the first failed project, if any.
+//
+func runWorkspace(rf *runfile.Runfile) int {
+	if len(os.Args) == 0 {
+		log.Println("ERROR: workspace mode requires a command")
+		showUsageHint()
+		return 2
+	}
+	cmdName := os.Args[0]
+	// Workspace root is the primary Runfile's dir, if present, else the current dir
+	//
+	root := config.RunfileAbsDir
+	if rf == nil {
+		var err error
+		if root, err = os.Getwd(); err != nil {
+			log.Printf("ERROR: %s", err)
+			return 2
+		}
+	}
+	files, err := workspaceRunfiles(rf, root)
+	if err != nil {
+		log.Printf("ERROR: %s", err)
+		return 2
+	}
+	if len(files) == 0 {
+		log.Printf("ERROR: no workspace runfiles found under %s", root)
+		return 2
+	}
+	jobs, err := workspaceJobs(rf)
+	if err != nil {
+		log.Printf("ERROR: %s", err)
+		return 2
+	}
+	// Invoke ourselves for each project
+	// Projects that do not define the command report workspaceNotDefined
+	//
+	bin, err := os.Executable()
+	if err != nil {
+		bin = config.RunBin
+	}
+	cmdArgs := append(append([]string{"--workspace-project"}, workspaceArgs()...), os.Args...)
+	projects := make([]*workspaceProject, len(files))
+	for i, file := range files {
+		projects[i] = &workspaceProject{name: util.TryMakeRelative(root, filepath.Dir(file)), runfile: file}
+	}
+	if config.WorkspaceParallel {
+		exitCodes := util.RunParallel(len(projects), jobs, func(i int, stdout io.Writer, stderr io.Writer) int {
+			runWorkspaceProject(bin, projects[i], cmdArgs, nil, stdout, stderr)
+			return projects[i].exitCode
+		}, func(i int, _ int) {
+			if projects[i].defined() {
+				_, _ = fmt.Fprintf(config.ErrOut, "==> %s\n", projects[i].name)
+			}
+		}, os.Stdout, os.Stderr)
+		for i, exitCode := range exitCodes {
+			projects[i].exitCode = exitCode
+		}
+	} else {
+		for _, project := range projects {
+			once := &sync.Once{}
+			stdout := &headerWriter{out: os.Stdout, project: project, once: once}
+			stderr := &headerWriter{out: os.Stderr, project: project, once: once}
+			runWorkspaceProject(bin, project, cmdArgs, os.Stdin, stdout, stderr)
+		}
+	}
+	definedCnt := 0
+	for _, project := range projects {
+		if project.defined() {
+			definedCnt++
+		}
+	}
+	if definedCnt == 0 {
+		log.Printf("ERROR: command not defined in any workspace runfile: %s", cmdName)
+		return 2
+	}
+	// Report
+	//
+	results := make([]util.JobResult, len(projects))
+	for i, project := range projects {
+		results[i] = util.JobResult{Name: project.name, ExitCode: project.exitCode}
+		if !project.defined() {
+			results[i].Skipped = cmdName + " not defined"
+		}
+	}
+	return util.ReportJobResults(config.ErrOut, "workspace results:", results)
+}
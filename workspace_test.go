@@ -0,0 +1,134 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tekwizely/run/internal/config"
+	"github.com/tekwizely/run/internal/runfile"
+)
+
+// writeWorkspace creates the files within a temp dir, returning the dir.
+//
+func writeWorkspace(t *testing.T, files map[string]string) string {
+	dir := t.TempDir()
+	for name, content := range files {
+		file := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+// setWorkspaceConfig sets the primary Runfile, restoring the previous config when the test completes.
+//
+func setWorkspaceConfig(t *testing.T, root string, parallel bool) {
+	runfileName, runfileAbs, runfileAbsDir, workspaceParallel := config.Runfile, config.RunfileAbs, config.RunfileAbsDir, config.WorkspaceParallel
+	errOut, args := config.ErrOut, os.Args
+	t.Cleanup(func() {
+		config.Runfile, config.RunfileAbs, config.RunfileAbsDir, config.WorkspaceParallel = runfileName, runfileAbs, runfileAbsDir, workspaceParallel
+		config.ErrOut, os.Args = errOut, args
+	})
+	config.Runfile = ""
+	config.RunfileAbs = filepath.Join(root, runfileDefault)
+	config.RunfileAbsDir = root
+	config.WorkspaceParallel = parallel
+}
+
+func TestWorkspaceRunfiles(t *testing.T) {
+	root := writeWorkspace(t, map[string]string{
+		"Runfile":              "",
+		"libs/common/Runfile":  "",
+		"services/api/Runfile": "",
+		"services/web/Runfile": "",
+		"docs/README":          "",
+	})
+	setWorkspaceConfig(t, root, false)
+	tests := []struct {
+		workspace string // Empty = not defined
+		want      []string
+	}{
+		{"", []string{"libs/common/Runfile", "services/api/Runfile", "services/web/Runfile"}},
+		{"services/*", []string{"services/api/Runfile", "services/web/Runfile"}},
+		{"libs/common services/api/Runfile libs/common", []string{"libs/common/Runfile", "services/api/Runfile"}},
+		{"docs .", []string{}},
+	}
+	for _, test := range tests {
+		rf := runfile.NewRunfile()
+		if len(test.workspace) > 0 {
+			rf.Scope.PutAttr(".WORKSPACE", test.workspace)
+		}
+		files, err := workspaceRunfiles(rf, root)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", test.workspace, err)
+			continue
+		}
+		got := []string{}
+		for _, file := range files {
+			got = append(got, filepath.ToSlash(strings.TrimPrefix(file, root+string(filepath.Separator))))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%q: got %q, want %q", test.workspace, got, test.want)
+		}
+	}
+}
+
+func TestWorkspaceJobs(t *testing.T) {
+	rf := runfile.NewRunfile()
+	if jobs, err := workspaceJobs(rf); err != nil || jobs < 1 {
+		t.Errorf("default: got %d, %v, want > 0", jobs, err)
+	}
+	rf.Scope.PutAttr(".WORKSPACE.JOBS", "3")
+	if jobs, err := workspaceJobs(rf); err != nil || jobs != 3 {
+		t.Errorf("3: got %d, %v, want 3", jobs, err)
+	}
+	for _, value := range []string{"0", "-1", "x"} {
+		rf.Scope.PutAttr(".WORKSPACE.JOBS", value)
+		if _, err := workspaceJobs(rf); err == nil {
+			t.Errorf("%s: expected error", value)
+		}
+	}
+}
+
+func TestRunWorkspace(t *testing.T) {
+	root := writeWorkspace(t, map[string]string{
+		"Runfile":              ".WORKSPACE.JOBS := 2\n",
+		"libs/common/Runfile":  "other:\n  echo other\n",
+		"services/api/Runfile": "test:\n  echo api\n",
+		"services/web/Runfile": "test:\n  exit 3\n",
+		"services/db/Runfile":  ".hidden:\n  echo hidden\n",
+		"services/ml/Runfile":  "test:\n  exit 120\n",
+	})
+	t.Setenv("RUN_TEST_MAIN", "1")
+	want := "workspace results:\n" +
+		"  SKIP  libs/common (test not defined)\n" +
+		"  PASS  services/api\n" +
+		"  SKIP  services/db (test not defined)\n" +
+		"  FAIL  services/ml (exit code 120)\n" +
+		"  FAIL  services/web (exit code 3)\n"
+	for _, parallel := range []bool{false, true} {
+		setWorkspaceConfig(t, root, parallel)
+		errOut := &bytes.Buffer{}
+		config.ErrOut = errOut
+		os.Args = []string{"test"}
+		if exitCode := runWorkspace(runfile.NewRunfile()); exitCode != 120 {
+			t.Errorf("parallel=%v: exit code = %d, want 120", parallel, exitCode)
+		}
+		if got := errOut.String(); !strings.HasSuffix(got, want) || strings.Contains(got, "==> libs/common") {
+			t.Errorf("parallel=%v: got output:\n%s\nwant results:\n%s", parallel, got, want)
+		}
+		os.Args = []string{"nope"}
+		if exitCode := runWorkspace(runfile.NewRunfile()); exitCode != 2 {
+			t.Errorf("parallel=%v: not defined: exit code = %d, want 2", parallel, exitCode)
+		}
+	}
+}